@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// how long to wait for the output pipes to be closed, once a command has been killed;
+// any orphaned child holding them would otherwise block us indefinitely
+const commandWaitDelay = time.Second
+
+// RunCommand runs an external command via the given executor and returns its standard output, like
+// exec.Command(...).Output() does, except that it gives up when the context expires.
+func RunCommand(ctx context.Context, executor Executor, path string, args ...string) ([]byte, error) {
+	output, err := executor.Command(ctx, path, args...).Output()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return output, errors.Wrapf(ctxErr, "'%s' did not complete in time", path)
+	}
+
+	return output, err
+}
+
+// newCommand returns a command started in a process group of its own, which is sent the given signal as a whole
+// when the context expires, so that no child process is left behind, e.g. blocked on an unresponsive device
+func newCommand(ctx context.Context, signal syscall.Signal, path string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, signal)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommand(t *testing.T) {
+	output, err := RunCommand(context.Background(), DirectExecutor{}, "echo", "foo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "foo\n", string(output))
+}
+
+func TestRunCommandFailure(t *testing.T) {
+	_, err := RunCommand(context.Background(), DirectExecutor{}, "false")
+
+	assert.Error(t, err)
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	// the child of the shell outlives it, unless the whole process group is killed
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	script := "sleep 30 & echo $! > " + pidFile + "; wait"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	_, err := RunCommand(ctx, DirectExecutor{}, "sh", "-c", script)
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(begin), commandWaitDelay)
+
+	pid, err := os.ReadFile(pidFile)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		// the killed child may linger as a zombie until it's reaped by its new parent
+		stat, err := os.ReadFile("/proc/" + strings.TrimSpace(string(pid)) + "/stat")
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	}, time.Second, 10*time.Millisecond)
+}
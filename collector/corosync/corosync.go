@@ -1,7 +1,9 @@
 package corosync
 
 import (
-	"os/exec"
+	"context"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -13,42 +15,107 @@ import (
 
 const subsystem = "corosync"
 
-func NewCollector(cfgToolPath string, quorumToolPath string, timestamps bool, logger log.Logger) (*corosyncCollector, error) {
-	err := collector.CheckExecutables(cfgToolPath, quorumToolPath)
+// the timeout of each request sent to corosync via IPC
+const ipcTimeout = 5 * time.Second
+
+// NewCollector creates the corosync collector; when useIPC is set, corosync is queried via its IPC interface,
+// and the command line tools are only used as a fallback; when logSource is set, either to a file path
+// or to JournalLogSource, the corosync logs are followed in the background to count the totem issues they report,
+// via journalctl in the latter case
+func NewCollector(cfgToolPath string, quorumToolPath string, cmapctlPath string, journalctlPath string, useIPC bool, logSource string, executor collector.Executor, timestamps bool, logger log.Logger) (*corosyncCollector, error) {
+	err := collector.CheckExecutables(cfgToolPath, quorumToolPath, cmapctlPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
 	}
 
+	parser := NewParser(cfgToolPath, quorumToolPath, cmapctlPath, executor)
+	if useIPC {
+		parser = NewFallbackParser(NewIPCParser(ipcTimeout), parser, logger)
+	}
+
 	c := &corosyncCollector{
 		collector.NewDefaultCollector(subsystem, timestamps, logger),
-		cfgToolPath,
-		quorumToolPath,
-		NewParser(),
+		parser,
+		&ringIdTracker{},
+		nil,
+	}
+
+	if logSource != "" {
+		c.logWatcher, err = newLogWatcher(logSource, journalctlPath, executor, c.Clock, logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
+		}
+		go c.logWatcher.watch()
 	}
+
 	c.SetDescriptor("quorate", "Whether or not the cluster is quorate", nil)
 	c.SetDescriptor("rings", "The status of each Corosync ring; 1 means healthy, 0 means faulty.", []string{"ring_id", "node_id", "number", "address"})
 	c.SetDescriptor("ring_errors", "The total number of faulty corosync rings", nil)
+	c.SetDescriptor("ring_seq", "The sequence number of the current ring id; it increases on every membership change", nil)
+	c.SetDescriptor("membership_changes_total", "The total number of ring id transitions observed since the exporter started", nil)
+	c.SetDescriptor("member_joins_total", "How many times each node has joined the membership since corosync started", []string{"node_id"})
+	c.SetDescriptor("node_info", "Information about each node configured in corosync; one line per node, per ring", []string{"node_id", "node", "number", "address"})
 	c.SetDescriptor("member_votes", "How many votes each member node has contributed with to the current quorum", []string{"node_id", "node", "local"})
 	c.SetDescriptor("quorum_votes", "Cluster quorum votes; one line per type", []string{"type"})
+	c.SetDescriptor("totem_srp_total", "Totem single ring protocol counters; one line per type", []string{"type"})
+	c.SetDescriptor("totem_srp", "Totem single ring protocol gauges; one line per type", []string{"type"})
+	c.SetDescriptor("totem_pg", "Totem process groups gauges; one line per type", []string{"type"})
+	c.SetDescriptor("ipcs_connections", "The number of active IPC connections to corosync", nil)
+	c.SetDescriptor("ipcs_connections_closed_total", "The total number of closed IPC connections to corosync", nil)
+	c.SetDescriptor("log_events_total", "The number of totem issues reported in the corosync logs since the exporter started; one line per type", []string{"type"})
+	c.SetDescriptor("log_event_last_timestamp_seconds", "The time of the last totem issue reported in the corosync logs; one line per type", []string{"type"})
+	c.SetDescriptor("ipcs_connection_stats", "IPC counters of the active connections, summed by client process; one line per process, per type", []string{"process", "type"})
 
 	return c, nil
 }
 
 type corosyncCollector struct {
 	collector.DefaultCollector
-	cfgToolPath    string
-	quorumToolPath string
-	parser         Parser
+	parser     Parser
+	ringIds    *ringIdTracker
+	logWatcher *logWatcher
+}
+
+// these totem srp statistics are instantaneous values, while all the others are monotonic counters
+var srpGauges = map[string]bool{
+	"avg_backlog_calc":                true,
+	"avg_token_workload":              true,
+	"continuous_gather":               true,
+	"continuous_sendmsg_failures":     true,
+	"firewall_enabled_or_nic_failure": true,
+	"mtt_rx_token":                    true,
+	"time_since_token_last_received":  true,
 }
 
-func (c *corosyncCollector) CollectWithError(ch chan<- prometheus.Metric) error {
+// keeps track of ring id transitions between scrapes; they are tracked by sequence number rather than by the ring id
+// string, because the latter is formatted differently by each corosync version and by the IPC and text parsers
+type ringIdTracker struct {
+	sync.Mutex
+	observed bool
+	last     uint64
+	changes  uint64
+}
+
+// records the given ring sequence number and returns the number of transitions observed so far;
+// the first sequence number we see is just the starting point, and is not counted as a change
+func (t *ringIdTracker) observe(ringSeq uint64) uint64 {
+	t.Lock()
+	defer t.Unlock()
+	if t.observed && t.last != ringSeq {
+		t.changes++
+	}
+	t.observed = true
+	t.last = ringSeq
+	return t.changes
+}
+
+func (c *corosyncCollector) CollectWithError(ctx context.Context, ch chan<- prometheus.Metric) error {
 	level.Debug(c.Logger).Log("msg", "Collecting corosync metrics...")
 
-	// We suppress the exec errors because if any interface is faulty the tools will exit with code 1, but we still want to parse the output.
-	cfgToolOutput, _ := exec.Command(c.cfgToolPath, "-s").Output()
-	quorumToolOutput, _ := exec.Command(c.quorumToolPath, "-p").Output()
+	// the log events do not depend on corosync being reachable, so we collect them first
+	c.collectLogEvents(ch)
 
-	status, err := c.parser.Parse(cfgToolOutput, quorumToolOutput)
+	status, err := c.parser.Parse(ctx)
 	if err != nil {
 		return errors.Wrap(err, "corosync parser error")
 	}
@@ -58,14 +125,27 @@ func (c *corosyncCollector) CollectWithError(ch chan<- prometheus.Metric) error
 	c.collectQuorate(status, ch)
 	c.collectQuorumVotes(status, ch)
 	c.collectMemberVotes(status, ch)
+	c.collectMembershipChanges(status, ch)
+	c.collectMemberJoins(status, ch)
+	c.collectNodes(status, ch)
+	c.collectTotemStats(status, ch)
+	c.collectIpcsStats(status, ch)
+
+	return nil
+}
 
+// Close stops following the corosync logs, if they are followed
+func (c *corosyncCollector) Close() error {
+	if c.logWatcher != nil {
+		c.logWatcher.close()
+	}
 	return nil
 }
 
 func (c *corosyncCollector) Collect(ch chan<- prometheus.Metric) {
 	level.Debug(c.Logger).Log("msg", "Collecting corosync metrics...")
 
-	err := c.CollectWithError(ch)
+	err := c.CollectWithError(context.Background(), ch)
 	if err != nil {
 		level.Warn(c.Logger).Log("msg", c.GetSubsystem()+" collector scrape failed", "err", err)
 	}
@@ -115,3 +195,74 @@ func (c *corosyncCollector) collectMemberVotes(status *Status, ch chan<- prometh
 		ch <- c.MakeGaugeMetric("member_votes", float64(member.Votes), member.Id, member.Name, local)
 	}
 }
+
+func (c *corosyncCollector) collectMembershipChanges(status *Status, ch chan<- prometheus.Metric) {
+	ch <- c.MakeGaugeMetric("ring_seq", float64(status.RingSeq))
+	ch <- c.MakeCounterMetric("membership_changes_total", float64(c.ringIds.observe(status.RingSeq)))
+}
+
+func (c *corosyncCollector) collectMemberJoins(status *Status, ch chan<- prometheus.Metric) {
+	for _, member := range status.RuntimeMembers {
+		ch <- c.MakeCounterMetric("member_joins_total", float64(member.JoinCount), member.Id)
+	}
+}
+
+func (c *corosyncCollector) collectNodes(status *Status, ch chan<- prometheus.Metric) {
+	for _, node := range status.Nodes {
+		for number, address := range node.Addresses {
+			ch <- c.MakeGaugeMetric("node_info", 1, node.Id, node.Name, number, address)
+		}
+	}
+}
+
+func (c *corosyncCollector) collectTotemStats(status *Status, ch chan<- prometheus.Metric) {
+	for name, value := range status.Stats.Srp {
+		if srpGauges[name] {
+			ch <- c.MakeGaugeMetric("totem_srp", float64(value), name)
+		} else {
+			ch <- c.MakeCounterMetric("totem_srp_total", float64(value), name)
+		}
+	}
+	for name, value := range status.Stats.Pg {
+		ch <- c.MakeGaugeMetric("totem_pg", float64(value), name)
+	}
+}
+
+func (c *corosyncCollector) collectLogEvents(ch chan<- prometheus.Metric) {
+	if c.logWatcher == nil {
+		return
+	}
+	counts, last := c.logWatcher.snapshot()
+	for event, count := range counts {
+		ch <- c.MakeCounterMetric("log_events_total", float64(count), event)
+	}
+	for event, at := range last {
+		ch <- c.MakeGaugeMetric("log_event_last_timestamp_seconds", float64(at.UnixNano())/1e9, event)
+	}
+}
+
+func (c *corosyncCollector) collectIpcsStats(status *Status, ch chan<- prometheus.Metric) {
+	ch <- c.MakeGaugeMetric("ipcs_connections", float64(status.Stats.Ipcs.Active))
+	ch <- c.MakeCounterMetric("ipcs_connections_closed_total", float64(status.Stats.Ipcs.Closed))
+	// these are only summed over the connections that are currently open, so they go down when a client reconnects
+	for process, counters := range status.Stats.Ipcs.Processes {
+		for name, value := range counters {
+			ch <- c.MakeGaugeMetric("ipcs_connection_stats", float64(value), process, name)
+		}
+	}
+}
+
+// Commands returns the commands the collector runs, with their arguments, e.g. to allow them in a sudoers policy;
+// journalctl is only among them when the corosync logs are followed from the journal
+func Commands(cfgToolPath string, quorumToolPath string, cmapctlPath string, journalctlPath string, logSource string) [][]string {
+	commands := [][]string{
+		{cfgToolPath, "-s"},
+		{quorumToolPath, "-p"},
+		{cmapctlPath},
+		{cmapctlPath, "-m", "stats"},
+	}
+	if logSource == JournalLogSource {
+		commands = append(commands, append([]string{journalctlPath}, journalArgs...))
+	}
+	return commands
+}
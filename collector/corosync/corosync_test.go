@@ -1,48 +1,113 @@
 package corosync
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
 )
 
 func TestNewCorosyncCollector(t *testing.T) {
-	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", false, "", collector.DirectExecutor{}, false, log.NewNopLogger())
 	assert.Nil(t, err)
 }
 
 func TestNewCorosyncCollectorChecksCfgtoolExistence(t *testing.T) {
-	_, err := NewCollector("../../test/nonexistent", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/nonexistent", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", false, "", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewCorosyncCollectorChecksQuorumtoolExistence(t *testing.T) {
-	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/nonexistent", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/nonexistent", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", false, "", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewCorosyncCollectorChecksCfgtoolExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/dummy", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/dummy", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", false, "", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestNewCorosyncCollectorChecksQuorumtoolExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/dummy", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/dummy", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", false, "", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
+func TestNewCorosyncCollectorChecksCmapctlExistence(t *testing.T) {
+	_, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/nonexistent", "../../test/fake_journalctl.sh", false, "", collector.DirectExecutor{}, false, log.NewNopLogger())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
+}
+
 func TestCorosyncCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", false, "", collector.DirectExecutor{}, false, log.NewNopLogger())
 	assertcustom.Metrics(t, collector, "corosync.metrics")
 }
+
+func TestRingIdTracker(t *testing.T) {
+	tracker := &ringIdTracker{}
+
+	assert.EqualValues(t, 0, tracker.observe(40))
+	assert.EqualValues(t, 0, tracker.observe(40))
+	assert.EqualValues(t, 1, tracker.observe(44))
+	assert.EqualValues(t, 2, tracker.observe(48))
+	assert.EqualValues(t, 2, tracker.observe(48))
+}
+
+func TestCorosyncCollectorFallsBackFromIPC(t *testing.T) {
+	// there is no corosync IPC service to connect to in the test environment, so the command line tools are used instead
+	collector, _ := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", true, "", collector.DirectExecutor{}, false, log.NewNopLogger())
+	assertcustom.Metrics(t, collector, "corosync.metrics")
+}
+
+func TestCorosyncCollectorWithLogSource(t *testing.T) {
+	fixture, err := os.ReadFile("../../test/corosync.log")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "corosync.log")
+
+	collector, err := NewCollector("../../test/fake_corosync-cfgtool.sh", "../../test/fake_corosync-quorumtool.sh", "../../test/fake_corosync-cmapctl.sh", "../../test/fake_journalctl.sh", false, path, collector.DirectExecutor{}, false, log.NewNopLogger())
+	require.NoError(t, err)
+	defer collector.Close()
+
+	// the log file is created after the collector, so that all of its lines are new
+	require.NoError(t, os.WriteFile(path, fixture, 0600))
+
+	expected := `# HELP ha_cluster_corosync_log_events_total The number of totem issues reported in the corosync logs since the exporter started; one line per type
+# TYPE ha_cluster_corosync_log_events_total counter
+ha_cluster_corosync_log_events_total{type="processor_failed"} 1
+ha_cluster_corosync_log_events_total{type="retransmit"} 2
+ha_cluster_corosync_log_events_total{type="token_not_received"} 1
+`
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCompare(collector, strings.NewReader(expected), "ha_cluster_corosync_log_events_total") == nil
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestCorosyncCommandsWithJournal(t *testing.T) {
+	commands := Commands("/usr/sbin/corosync-cfgtool", "/usr/sbin/corosync-quorumtool", "/usr/sbin/corosync-cmapctl", "/usr/bin/journalctl", JournalLogSource)
+
+	assert.Equal(t, [][]string{
+		{"/usr/sbin/corosync-cfgtool", "-s"},
+		{"/usr/sbin/corosync-quorumtool", "-p"},
+		{"/usr/sbin/corosync-cmapctl"},
+		{"/usr/sbin/corosync-cmapctl", "-m", "stats"},
+		{"/usr/bin/journalctl", "--follow", "--lines=0", "--output=export", "--unit=corosync.service"},
+	}, commands)
+}
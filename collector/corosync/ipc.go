@@ -0,0 +1,490 @@
+package corosync
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/corosync/libqb"
+)
+
+// the message types and the C structs below come from the corosync IPC headers, i.e. ipc_cmap.h, ipc_quorum.h and ipc_votequorum.h
+
+// cs_error_t values
+const (
+	csOk            = 1
+	csErrNoSections = 27
+)
+
+const (
+	cmapReqGet           = 2
+	cmapReqIterInit      = 4
+	cmapReqIterNext      = 5
+	cmapReqIterFinalize  = 6
+	cmapReqSetCurrentMap = 9
+	// the statistics map, only available in corosync v2.99+
+	cmapMapStats = 1
+
+	// mar_name_t: uint16_t length and 256 bytes of value, both 8 bytes aligned
+	marNameSize = 8 + 256
+)
+
+// cmap_value_types_t values
+const (
+	cmapTypeInt8 = iota + 1
+	cmapTypeUint8
+	cmapTypeInt16
+	cmapTypeUint16
+	cmapTypeInt32
+	cmapTypeUint32
+	cmapTypeInt64
+	cmapTypeUint64
+	cmapTypeFloat
+	cmapTypeDouble
+	cmapTypeString
+	cmapTypeBinary
+)
+
+const (
+	quorumReqTrackStart    = 1
+	quorumResNotification  = 3
+	quorumTrackCurrent     = 1
+	votequorumReqGetInfo   = 0
+	votequorumQdeviceFlag  = 64
+	votequorumQdeviceAlive = 128
+	votequorumQdeviceVote  = 256
+	votequorumQdeviceMW    = 512
+)
+
+// struct res_lib_votequorum_getinfo, without its header
+type votequorumInfo struct {
+	NodeId          uint32
+	State           uint32
+	Votes           uint32
+	ExpectedVotes   uint32
+	HighestExpected uint32
+	TotalVotes      uint32
+	Quorum          uint32
+	Flags           uint32
+	QdeviceVotes    uint32
+	QdeviceName     [255]byte
+}
+
+// struct res_lib_quorum_notification, without its header
+type quorumNotification struct {
+	Quorate bool
+	RingSeq uint64
+	NodeIds []uint32
+}
+
+// NewIPCParser returns a Parser that queries the corosync services directly via their libqb IPC interface,
+// the same way the corosync command line tools do
+func NewIPCParser(timeout time.Duration) Parser {
+	return &ipcParser{timeout}
+}
+
+type ipcParser struct {
+	timeout time.Duration
+}
+
+// all the requests share the timeout, and are aborted as soon as the context expires,
+// so that the fallback parser can still run within the scrape
+func (p *ipcParser) Parse(ctx context.Context) (*Status, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	cmap, err := p.readCmap(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read cmap")
+	}
+
+	notification, err := p.readQuorum(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read quorum")
+	}
+
+	infos, err := p.readVotequorum(ctx, notification.NodeIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read votequorum")
+	}
+
+	return buildIPCStatus(cmap, notification, infos)
+}
+
+// reads the whole main cmap database and, when available, the statistics one, like `corosync-cmapctl` does;
+// cmap has no bulk get, so this takes two round trips per key
+func (p *ipcParser) readCmap(ctx context.Context) (map[string]string, error) {
+	client, err := libqb.Dial(ctx, "cmap")
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	cmap := make(map[string]string)
+	err = dumpCmap(ctx, client, cmap)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, 8)
+	binary.NativeEndian.PutUint32(payload, cmapMapStats)
+	_, err = ipcRequest(ctx, client, cmapReqSetCurrentMap, payload)
+	if err != nil {
+		// corosync < v2.99 keeps the statistics in the main map
+		return cmap, nil
+	}
+	err = dumpCmap(ctx, client, cmap)
+	if err != nil {
+		return nil, err
+	}
+
+	return cmap, nil
+}
+
+func dumpCmap(ctx context.Context, client *libqb.Client, cmap map[string]string) error {
+	payload, err := ipcRequest(ctx, client, cmapReqIterInit, marName(""))
+	if err != nil {
+		return errors.Wrap(err, "could not start iteration")
+	}
+	if len(payload) < 8 {
+		return errors.New("invalid iteration handle")
+	}
+	handle := payload[:8]
+	defer ipcRequest(ctx, client, cmapReqIterFinalize, handle)
+
+	for {
+		response, err := client.Request(ctx, cmapReqIterNext, handle)
+		if err != nil {
+			return err
+		}
+		if response.Error == csErrNoSections {
+			return nil
+		}
+		if response.Error != csOk {
+			return errors.Errorf("iteration failed with error %d", response.Error)
+		}
+		if len(response.Payload) < marNameSize+8 {
+			return errors.New("invalid iteration response")
+		}
+		key := parseMarName(response.Payload)
+		valueLen := response.Payload[marNameSize : marNameSize+8]
+
+		payload, err := ipcRequest(ctx, client, cmapReqGet, append(marName(key), valueLen...))
+		if err != nil {
+			return errors.Wrapf(err, "could not get %s", key)
+		}
+		value, ok, err := parseCmapValue(payload)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse %s", key)
+		}
+		if ok {
+			cmap[key] = value
+		}
+	}
+}
+
+// tracks the current quorum state, which is sent back as an event, like `corosync-quorumtool` does
+func (p *ipcParser) readQuorum(ctx context.Context) (notification quorumNotification, err error) {
+	client, err := libqb.Dial(ctx, "quorum")
+	if err != nil {
+		return notification, err
+	}
+	defer client.Close()
+
+	payload := make([]byte, 8)
+	binary.NativeEndian.PutUint32(payload, quorumTrackCurrent)
+	_, err = ipcRequest(ctx, client, quorumReqTrackStart, payload)
+	if err != nil {
+		return notification, errors.Wrap(err, "could not start tracking")
+	}
+
+	event, err := client.ReadEvent(ctx)
+	if err != nil {
+		return notification, errors.Wrap(err, "could not read notification")
+	}
+	if event.Id != quorumResNotification {
+		return notification, errors.Errorf("unexpected event %d", event.Id)
+	}
+
+	return parseQuorumNotification(event.Payload)
+}
+
+// gets the votequorum information of the local node, followed by the ones of the given nodes
+func (p *ipcParser) readVotequorum(ctx context.Context, nodeIds []uint32) ([]votequorumInfo, error) {
+	client, err := libqb.Dial(ctx, "votequorum")
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// node id 0 stands for the local node
+	infos := make([]votequorumInfo, 0, len(nodeIds)+1)
+	for _, nodeId := range append([]uint32{0}, nodeIds...) {
+		payload := make([]byte, 8)
+		binary.NativeEndian.PutUint32(payload, nodeId)
+		response, err := ipcRequest(ctx, client, votequorumReqGetInfo, payload)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get info of node %d", nodeId)
+		}
+		var info votequorumInfo
+		err = binary.Read(bytes.NewReader(response), binary.NativeEndian, &info)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse info of node %d", nodeId)
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// sends a request and returns the payload of the response, if the corosync service reports no error
+func ipcRequest(ctx context.Context, client *libqb.Client, id int32, payload []byte) ([]byte, error) {
+	response, err := client.Request(ctx, id, payload)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != csOk {
+		return nil, errors.Errorf("request %d failed with error %d", id, response.Error)
+	}
+	return response.Payload, nil
+}
+
+func marName(name string) []byte {
+	b := make([]byte, marNameSize)
+	binary.NativeEndian.PutUint16(b, uint16(len(name)))
+	copy(b[8:], name)
+	return b
+}
+
+func parseMarName(b []byte) string {
+	length := int(binary.NativeEndian.Uint16(b))
+	if length > marNameSize-8 {
+		length = marNameSize - 8
+	}
+	return strings.TrimRight(string(b[8:8+length]), "\x00")
+}
+
+// parses the struct res_lib_cmap_get payload, i.e. the 8 bytes aligned value length and type, followed by the value;
+// values are formatted like `corosync-cmapctl` does, while binary ones are skipped
+func parseCmapValue(b []byte) (string, bool, error) {
+	if len(b) < 16 {
+		return "", false, errors.New("response too short")
+	}
+	length := binary.NativeEndian.Uint64(b)
+	valueType := b[8]
+	if uint64(len(b)-16) < length {
+		return "", false, errors.New("value too short")
+	}
+	value := b[16 : 16+length]
+
+	var size uint64
+	switch valueType {
+	case cmapTypeInt8, cmapTypeUint8:
+		size = 1
+	case cmapTypeInt16, cmapTypeUint16:
+		size = 2
+	case cmapTypeInt32, cmapTypeUint32, cmapTypeFloat:
+		size = 4
+	case cmapTypeInt64, cmapTypeUint64, cmapTypeDouble:
+		size = 8
+	}
+	if length < size {
+		return "", false, errors.Errorf("value too short for type %d", valueType)
+	}
+
+	switch valueType {
+	case cmapTypeInt8:
+		return strconv.FormatInt(int64(int8(value[0])), 10), true, nil
+	case cmapTypeUint8:
+		return strconv.FormatUint(uint64(value[0]), 10), true, nil
+	case cmapTypeInt16:
+		return strconv.FormatInt(int64(int16(binary.NativeEndian.Uint16(value))), 10), true, nil
+	case cmapTypeUint16:
+		return strconv.FormatUint(uint64(binary.NativeEndian.Uint16(value)), 10), true, nil
+	case cmapTypeInt32:
+		return strconv.FormatInt(int64(int32(binary.NativeEndian.Uint32(value))), 10), true, nil
+	case cmapTypeUint32:
+		return strconv.FormatUint(uint64(binary.NativeEndian.Uint32(value)), 10), true, nil
+	case cmapTypeInt64:
+		return strconv.FormatInt(int64(binary.NativeEndian.Uint64(value)), 10), true, nil
+	case cmapTypeUint64:
+		return strconv.FormatUint(binary.NativeEndian.Uint64(value), 10), true, nil
+	case cmapTypeFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.NativeEndian.Uint32(value))), 'f', 6, 32), true, nil
+	case cmapTypeDouble:
+		return strconv.FormatFloat(math.Float64frombits(binary.NativeEndian.Uint64(value)), 'f', 6, 64), true, nil
+	case cmapTypeString:
+		return strings.TrimRight(string(value), "\x00"), true, nil
+	}
+	return "", false, nil
+}
+
+// parses the struct res_lib_quorum_notification payload, i.e. the 8 bytes aligned quorate flag, ring sequence number
+// and number of nodes, followed by the node ids
+func parseQuorumNotification(b []byte) (notification quorumNotification, err error) {
+	if len(b) < 20 {
+		return notification, errors.New("notification too short")
+	}
+	notification.Quorate = binary.NativeEndian.Uint32(b) != 0
+	notification.RingSeq = binary.NativeEndian.Uint64(b[8:])
+	entries := binary.NativeEndian.Uint32(b[16:])
+	if uint64(len(b)-20) < uint64(entries)*4 {
+		return notification, errors.New("notification node list too short")
+	}
+	for i := uint32(0); i < entries; i++ {
+		notification.NodeIds = append(notification.NodeIds, binary.NativeEndian.Uint32(b[20+i*4:]))
+	}
+	return notification, nil
+}
+
+// builds the same status the text parser would produce from the command line tools output;
+// the first votequorum info is the one of the local node, and the others the ones of the nodes in the notification
+func buildIPCStatus(cmap map[string]string, notification quorumNotification, infos []votequorumInfo) (*Status, error) {
+	if len(infos) != len(notification.NodeIds)+1 {
+		return nil, errors.New("missing votequorum information")
+	}
+	local := infos[0]
+	status := &Status{
+		NodeId:  strconv.FormatUint(uint64(local.NodeId), 10),
+		RingId:  formatRingId(notification),
+		RingSeq: notification.RingSeq,
+		Quorate: notification.Quorate,
+		QuorumVotes: QuorumVotes{
+			ExpectedVotes:   uint64(local.ExpectedVotes),
+			HighestExpected: uint64(local.HighestExpected),
+			TotalVotes:      uint64(local.TotalVotes),
+			Quorum:          uint64(local.Quorum),
+		},
+	}
+
+	for _, info := range infos[1:] {
+		status.Members = append(status.Members, Member{
+			Id:      strconv.FormatUint(uint64(info.NodeId), 10),
+			Qdevice: qdeviceState(info.Flags),
+			Votes:   uint64(info.Votes),
+			Local:   info.NodeId == local.NodeId,
+		})
+	}
+	if local.Flags&votequorumQdeviceFlag != 0 {
+		status.Members = append(status.Members, Member{
+			Id:    "0",
+			Name:  "Qdevice",
+			Votes: uint64(local.QdeviceVotes),
+		})
+	}
+
+	status.Rings = parseCmapRings(cmap, status.NodeId)
+
+	var err error
+	status.RuntimeMembers, err = parseRuntimeMembers(cmap)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse runtime members in cmap")
+	}
+
+	status.Nodes = parseNodelist(cmap)
+	resolveNodeNames(status)
+	// the members are named after the nodes, or after their id when they are not in the nodelist
+	for i := range status.Members {
+		member := &status.Members[i]
+		if member.Name != "" {
+			continue
+		}
+		member.Name = member.Id
+		for _, node := range status.Nodes {
+			if node.Id == member.Id {
+				member.Name = node.Name
+			}
+		}
+	}
+
+	status.Stats, err = parseStats(cmap)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse statistics in cmap")
+	}
+
+	return status, nil
+}
+
+// formats the ring id like `corosync-quorumtool` in corosync v2.99+ does, i.e. with both its parts in hexadecimal, e.g. `a.2c`;
+// the quorum notification only carries the sequence number, but the representative of the ring is always the member with the lowest node id
+func formatRingId(notification quorumNotification) string {
+	if len(notification.NodeIds) == 0 {
+		return strconv.FormatUint(notification.RingSeq, 16)
+	}
+	representative := notification.NodeIds[0]
+	for _, nodeId := range notification.NodeIds[1:] {
+		if nodeId < representative {
+			representative = nodeId
+		}
+	}
+	return fmt.Sprintf("%x.%x", representative, notification.RingSeq)
+}
+
+// the qdevice state of a node, as printed by `corosync-quorumtool`
+func qdeviceState(flags uint32) string {
+	if flags&votequorumQdeviceFlag == 0 {
+		return "NR"
+	}
+	states := []string{"NA", "NV", "NMW"}
+	if flags&votequorumQdeviceAlive != 0 {
+		states[0] = "A"
+	}
+	if flags&votequorumQdeviceVote != 0 {
+		states[1] = "V"
+	}
+	if flags&votequorumQdeviceMW != 0 {
+		states[2] = "MW"
+	}
+	return strings.Join(states, ",")
+}
+
+// builds the local rings from the local node addresses in the nodelist, and their state from the statistics:
+// corosync < v2.99 flags faulty rings in the `runtime.totem.pg.mrp.rrp.<number>.faulty` keys, while
+// corosync v2.99+ keeps the state of each knet link towards each node in the `stats.knet.node<id>.link<number>.connected` keys;
+// like `corosync-cfgtool -s` does, a link is considered faulty as soon as it is disconnected from any of the joined nodes
+func parseCmapRings(cmap map[string]string, nodeId string) []Ring {
+	position, ok := cmap["nodelist.local_node_pos"]
+	if !ok {
+		return nil
+	}
+
+	addressRe := regexp.MustCompile(`^nodelist\.node\.` + regexp.QuoteMeta(position) + `\.ring(\d+)_addr$`)
+	knetRe := regexp.MustCompile(`^stats\.knet\.node(\d+)\.link(\d+)\.connected$`)
+
+	var rings []Ring
+	for key, address := range cmap {
+		match := addressRe.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		ring := Ring{
+			Number:  match[1],
+			Address: address,
+			Faulty:  cmap["runtime.totem.pg.mrp.rrp."+match[1]+".faulty"] == "1",
+		}
+		for key, connected := range cmap {
+			knetMatch := knetRe.FindStringSubmatch(key)
+			if knetMatch == nil || knetMatch[2] != ring.Number || knetMatch[1] == nodeId {
+				continue
+			}
+			if connected == "0" && cmap["runtime.members."+knetMatch[1]+".status"] == "joined" {
+				ring.Faulty = true
+			}
+		}
+		rings = append(rings, ring)
+	}
+
+	sort.Slice(rings, func(i, j int) bool {
+		return rings[i].Number < rings[j].Number
+	})
+	return rings
+}
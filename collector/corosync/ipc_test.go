@@ -0,0 +1,240 @@
+package corosync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func cmapValue(valueType byte, value []byte) []byte {
+	b := make([]byte, 16+len(value))
+	binary.NativeEndian.PutUint64(b, uint64(len(value)))
+	b[8] = valueType
+	copy(b[16:], value)
+	return b
+}
+
+func TestMarName(t *testing.T) {
+	b := marName("runtime.members.1.status")
+
+	assert.Len(t, b, marNameSize)
+	assert.Equal(t, "runtime.members.1.status", parseMarName(b))
+}
+
+func TestParseCmapValue(t *testing.T) {
+	u32 := make([]byte, 4)
+	binary.NativeEndian.PutUint32(u32, 4294967295)
+	i64 := make([]byte, 8)
+	binary.NativeEndian.PutUint64(i64, uint64(0xFFFFFFFFFFFFFFFF))
+	f64 := make([]byte, 8)
+	binary.NativeEndian.PutUint64(f64, math.Float64bits(1.5))
+
+	testCases := []struct {
+		payload  []byte
+		expected string
+	}{
+		{cmapValue(cmapTypeUint8, []byte{1}), "1"},
+		{cmapValue(cmapTypeInt8, []byte{0xFF}), "-1"},
+		{cmapValue(cmapTypeUint32, u32), "4294967295"},
+		{cmapValue(cmapTypeInt64, i64), "-1"},
+		{cmapValue(cmapTypeDouble, f64), "1.500000"},
+		{cmapValue(cmapTypeString, []byte("joined\x00")), "joined"},
+	}
+
+	for _, testCase := range testCases {
+		value, ok, err := parseCmapValue(testCase.payload)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, testCase.expected, value)
+	}
+}
+
+func TestParseCmapValueSkipsBinary(t *testing.T) {
+	_, ok, err := parseCmapValue(cmapValue(cmapTypeBinary, []byte{1, 2, 3}))
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestParseCmapValueError(t *testing.T) {
+	_, _, err := parseCmapValue(cmapValue(cmapTypeUint64, []byte{1, 2, 3}))
+
+	assert.EqualError(t, err, "value too short for type 8")
+}
+
+func TestParseQuorumNotification(t *testing.T) {
+	b := make([]byte, 28)
+	binary.NativeEndian.PutUint32(b, 1)
+	binary.NativeEndian.PutUint64(b[8:], 68)
+	binary.NativeEndian.PutUint32(b[16:], 2)
+	binary.NativeEndian.PutUint32(b[20:], 1084783375)
+	binary.NativeEndian.PutUint32(b[24:], 1084783376)
+
+	notification, err := parseQuorumNotification(b)
+
+	assert.NoError(t, err)
+	assert.True(t, notification.Quorate)
+	assert.Equal(t, uint64(68), notification.RingSeq)
+	assert.Equal(t, []uint32{1084783375, 1084783376}, notification.NodeIds)
+}
+
+func TestParseQuorumNotificationError(t *testing.T) {
+	b := make([]byte, 24)
+	binary.NativeEndian.PutUint32(b[16:], 2)
+
+	_, err := parseQuorumNotification(b)
+
+	assert.EqualError(t, err, "notification node list too short")
+}
+
+func TestQdeviceState(t *testing.T) {
+	assert.Equal(t, "NR", qdeviceState(0))
+	assert.Equal(t, "NA,NV,NMW", qdeviceState(votequorumQdeviceFlag))
+	assert.Equal(t, "A,V,NMW", qdeviceState(votequorumQdeviceFlag|votequorumQdeviceAlive|votequorumQdeviceVote))
+	assert.Equal(t, "A,V,MW", qdeviceState(votequorumQdeviceFlag|votequorumQdeviceAlive|votequorumQdeviceVote|votequorumQdeviceMW))
+}
+
+func TestParseCmapRingsInCorosyncV2(t *testing.T) {
+	cmap := map[string]string{
+		"nodelist.local_node_pos":           "0",
+		"nodelist.node.0.nodeid":            "1",
+		"nodelist.node.0.ring0_addr":        "10.0.0.1",
+		"nodelist.node.0.ring1_addr":        "10.0.1.1",
+		"nodelist.node.1.nodeid":            "2",
+		"nodelist.node.1.ring0_addr":        "10.0.0.2",
+		"runtime.totem.pg.mrp.rrp.0.faulty": "0",
+		"runtime.totem.pg.mrp.rrp.1.faulty": "1",
+	}
+
+	rings := parseCmapRings(cmap, "1")
+
+	assert.Equal(t, []Ring{
+		{Number: "0", Address: "10.0.0.1", Faulty: false},
+		{Number: "1", Address: "10.0.1.1", Faulty: true},
+	}, rings)
+}
+
+func TestParseCmapRings(t *testing.T) {
+	cmap := map[string]string{
+		"nodelist.local_node_pos":          "1",
+		"nodelist.node.0.nodeid":           "1",
+		"nodelist.node.0.ring0_addr":       "10.0.0.1",
+		"nodelist.node.1.nodeid":           "2",
+		"nodelist.node.1.ring0_addr":       "10.0.0.2",
+		"nodelist.node.1.ring1_addr":       "10.0.1.2",
+		"nodelist.node.2.nodeid":           "3",
+		"runtime.members.1.status":         "joined",
+		"runtime.members.2.status":         "joined",
+		"runtime.members.3.status":         "left",
+		"stats.knet.node1.link0.connected": "1",
+		"stats.knet.node1.link1.connected": "0",
+		"stats.knet.node2.link0.connected": "1",
+		"stats.knet.node2.link1.connected": "1",
+		"stats.knet.node3.link0.connected": "0",
+		"stats.knet.node3.link1.connected": "0",
+	}
+
+	rings := parseCmapRings(cmap, "2")
+
+	assert.Equal(t, []Ring{
+		{Number: "0", Address: "10.0.0.2", Faulty: false},
+		{Number: "1", Address: "10.0.1.2", Faulty: true},
+	}, rings)
+}
+
+func TestParseCmapRingsWithoutNodelist(t *testing.T) {
+	assert.Nil(t, parseCmapRings(map[string]string{}, "1"))
+}
+
+func TestBuildIPCStatus(t *testing.T) {
+	cmap := map[string]string{
+		"nodelist.local_node_pos":               "0",
+		"nodelist.node.0.nodeid":                "1084783375",
+		"nodelist.node.0.name":                  "stefanotorresi-hana01",
+		"nodelist.node.0.ring0_addr":            "10.0.0.1",
+		"nodelist.node.1.nodeid":                "1084783376",
+		"nodelist.node.1.ring0_addr":            "10.0.0.2",
+		"runtime.members.1084783375.join_count": "1",
+		"runtime.members.1084783375.status":     "joined",
+		"runtime.totem.pg.mrp.rrp.0.faulty":     "0",
+		"stats.srp.orf_token_rx":                "2782",
+		"stats.pg.msg_queue_avail":              "0",
+		"stats.ipcs.global.active":              "9",
+	}
+	notification := quorumNotification{
+		Quorate: true,
+		RingSeq: 68,
+		NodeIds: []uint32{1084783375, 1084783376},
+	}
+	infos := []votequorumInfo{
+		{NodeId: 1084783375, Votes: 1, ExpectedVotes: 2, HighestExpected: 2, TotalVotes: 3, Quorum: 2, Flags: votequorumQdeviceFlag, QdeviceVotes: 1},
+		{NodeId: 1084783375, Votes: 1},
+		{NodeId: 1084783376, Votes: 1, Flags: votequorumQdeviceFlag | votequorumQdeviceAlive | votequorumQdeviceVote},
+	}
+
+	status, err := buildIPCStatus(cmap, notification, infos)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1084783375", status.NodeId)
+	assert.Equal(t, "40a87b0f.44", status.RingId)
+	assert.Equal(t, uint64(68), status.RingSeq)
+	assert.True(t, status.Quorate)
+	assert.Equal(t, QuorumVotes{ExpectedVotes: 2, HighestExpected: 2, TotalVotes: 3, Quorum: 2}, status.QuorumVotes)
+	assert.Equal(t, []Member{
+		{Id: "1084783375", Name: "stefanotorresi-hana01", Qdevice: "NR", Votes: 1, Local: true},
+		{Id: "1084783376", Name: "10.0.0.2", Qdevice: "A,V,NMW", Votes: 1},
+		{Id: "0", Name: "Qdevice", Votes: 1},
+	}, status.Members)
+	assert.Equal(t, []Ring{{Number: "0", Address: "10.0.0.1"}}, status.Rings)
+	assert.Equal(t, []RuntimeMember{{Id: "1084783375", Status: "joined", JoinCount: 1}}, status.RuntimeMembers)
+	assert.Equal(t, uint64(2782), status.Stats.Srp["orf_token_rx"])
+	assert.Equal(t, uint64(9), status.Stats.Ipcs.Active)
+}
+
+func TestFormatRingId(t *testing.T) {
+	testCases := map[string]quorumNotification{
+		// both parts are hexadecimal, so node ids from 10 on differ from their decimal form
+		"a.2c": {RingSeq: 44, NodeIds: []uint32{12, 10, 11}},
+		"1.2c": {RingSeq: 44, NodeIds: []uint32{2, 1}},
+		"2c":   {RingSeq: 44},
+	}
+	for expected, notification := range testCases {
+		t.Run(expected, func(t *testing.T) {
+			assert.Equal(t, expected, formatRingId(notification))
+		})
+	}
+}
+
+func TestBuildIPCStatusMissingVotequorumInfo(t *testing.T) {
+	_, err := buildIPCStatus(map[string]string{}, quorumNotification{NodeIds: []uint32{1}}, []votequorumInfo{{}})
+
+	assert.EqualError(t, err, "missing votequorum information")
+}
+
+type stubParser struct {
+	status *Status
+	err    error
+}
+
+func (p *stubParser) Parse(_ context.Context) (*Status, error) {
+	return p.status, p.err
+}
+
+func TestFallbackParser(t *testing.T) {
+	primary := &stubParser{&Status{NodeId: "1"}, nil}
+	fallback := &stubParser{&Status{NodeId: "2"}, nil}
+
+	status, err := NewFallbackParser(primary, fallback, log.NewNopLogger()).Parse(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "1", status.NodeId)
+
+	primary.err = errors.New("no IPC")
+	status, err = NewFallbackParser(primary, fallback, log.NewNopLogger()).Parse(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "2", status.NodeId)
+}
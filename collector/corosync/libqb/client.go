@@ -0,0 +1,242 @@
+// Package libqb implements the client side of the libqb IPC protocol, used by the corosync services,
+// over its shared memory transport.
+package libqb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// struct qb_ipc_request_header: int32_t id and int32_t size, both 8 bytes aligned
+	RequestHeaderSize = 16
+	// struct qb_ipc_response_header: int32_t id, int32_t size and int32_t error, all 8 bytes aligned
+	ResponseHeaderSize = 24
+
+	msgAuthenticate = -1
+	// the connection type of the shared memory transport, in enum qb_ipc_type
+	ipcTypeShm = 1
+	// the same maximum message size requested by the corosync client libraries
+	maxMsgSize = 8192 * 128
+
+	connectionRequestSize = RequestHeaderSize + 8
+	// struct qb_ipc_connection_response: the response header, followed by the connection type, the maximum message
+	// size and the connection id, all 8 bytes aligned, and then the names of the request, response and event ring buffers
+	connectionResponseSize = ResponseHeaderSize + 3*8 + 3*pathMax
+
+	// how long to block on a ring buffer semaphore at once, before checking whether the response is still awaited
+	waitInterval = 50 * time.Millisecond
+)
+
+// Response is a message received from a libqb server, either in reply to a request or as an event
+type Response struct {
+	Id int32
+	// the error code is service specific, e.g. a cs_error_t for corosync
+	Error   int32
+	Payload []byte
+}
+
+// Client is a connection to a libqb IPC server
+type Client struct {
+	conn     *net.UnixConn
+	request  *ringBuffer
+	response *ringBuffer
+	event    *ringBuffer
+}
+
+// Dial connects to the IPC service with the given name, e.g. `cmap`; it gives up when the context expires.
+func Dial(ctx context.Context, service string) (*Client, error) {
+	// on Linux, libqb servers listen on abstract Unix sockets named after the service
+	return dial(ctx, "@"+service)
+}
+
+func dial(ctx context.Context, address string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", address)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect")
+	}
+	c := &Client{conn: conn.(*net.UnixConn)}
+
+	err = c.handshake(ctx)
+	if err != nil {
+		c.Close()
+		return nil, errors.Wrap(err, "could not set up the connection")
+	}
+
+	return c, nil
+}
+
+func (c *Client) handshake(ctx context.Context) error {
+	rawConn, err := c.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = setPassCred(fd)
+	})
+	if err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return sockErr
+	}
+
+	defer c.interruptSocket(ctx)()
+
+	request := make([]byte, connectionRequestSize)
+	putHeader(request, msgAuthenticate)
+	binary.NativeEndian.PutUint32(request[RequestHeaderSize:], maxMsgSize)
+	_, err = c.conn.Write(request)
+	if err != nil {
+		return err
+	}
+
+	response := make([]byte, connectionResponseSize)
+	_, err = io.ReadFull(c.conn, response)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+
+	// the server replies with a negative errno, e.g. when the client is not authorized
+	if errno := int32(binary.NativeEndian.Uint32(response[16:])); errno != 0 {
+		return errors.Wrap(syscall.Errno(-errno), "connection refused")
+	}
+	if connectionType := int32(binary.NativeEndian.Uint32(response[ResponseHeaderSize:])); connectionType != ipcTypeShm {
+		return errors.Errorf("unsupported connection type %d", connectionType)
+	}
+
+	names := response[ResponseHeaderSize+3*8:]
+	c.request, err = openRingBuffer(cString(names[:pathMax]))
+	if err != nil {
+		return errors.Wrap(err, "could not open the request ring buffer")
+	}
+	c.response, err = openRingBuffer(cString(names[pathMax : 2*pathMax]))
+	if err != nil {
+		return errors.Wrap(err, "could not open the response ring buffer")
+	}
+	c.event, err = openRingBuffer(cString(names[2*pathMax:]))
+	if err != nil {
+		return errors.Wrap(err, "could not open the event ring buffer")
+	}
+
+	return nil
+}
+
+// interruptSocket makes the pending and future I/O on the setup socket fail as soon as the context expires,
+// until the returned function is called
+func (c *Client) interruptSocket(ctx context.Context) func() {
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		if !stop() {
+			return
+		}
+		c.conn.SetDeadline(time.Time{})
+	}
+}
+
+// Request sends a message with the given id and payload, and waits for the server to reply, until the context expires;
+// the payload must already be padded like the C struct the server expects.
+func (c *Client) Request(ctx context.Context, id int32, payload []byte) (*Response, error) {
+	message := make([]byte, RequestHeaderSize+len(payload))
+	putHeader(message, id)
+	copy(message[RequestHeaderSize:], payload)
+
+	err := c.request.write(message)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not send request")
+	}
+
+	// the server polls the setup socket, so we need to wake it up
+	release := c.interruptSocket(ctx)
+	_, err = c.conn.Write(message[:1])
+	release()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, errors.Wrap(err, "could not notify request")
+	}
+
+	return c.receive(ctx, c.response)
+}
+
+// ReadEvent waits for the next event sent by the server, e.g. a notification after a tracking request,
+// until the context expires
+func (c *Client) ReadEvent(ctx context.Context) (*Response, error) {
+	return c.receive(ctx, c.event)
+}
+
+// receive reads the next chunk of a ring buffer, blocking on its semaphore, which the server posts for each chunk it writes,
+// like the libqb clients do; it checks whether the context expired at least every waitInterval
+func (c *Client) receive(ctx context.Context, rb *ringBuffer) (*Response, error) {
+	for {
+		chunk, ok, err := rb.read()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return parseResponse(chunk)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, "gave up waiting for a response")
+		}
+
+		wait := waitInterval
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			wait = time.Until(deadline)
+		}
+		rb.semWait(wait)
+	}
+}
+
+func parseResponse(chunk []byte) (*Response, error) {
+	if len(chunk) < ResponseHeaderSize {
+		return nil, errors.Errorf("response too short: %d bytes", len(chunk))
+	}
+	size := int(int32(binary.NativeEndian.Uint32(chunk[8:])))
+	if size < ResponseHeaderSize || size > len(chunk) {
+		return nil, errors.Errorf("invalid response size %d", size)
+	}
+
+	return &Response{
+		Id:      int32(binary.NativeEndian.Uint32(chunk[0:])),
+		Error:   int32(binary.NativeEndian.Uint32(chunk[16:])),
+		Payload: chunk[ResponseHeaderSize:size],
+	}, nil
+}
+
+// Close disconnects from the server and releases the ring buffers
+func (c *Client) Close() error {
+	for _, rb := range []*ringBuffer{c.request, c.response, c.event} {
+		if rb != nil {
+			rb.close()
+		}
+	}
+	return c.conn.Close()
+}
+
+func putHeader(message []byte, id int32) {
+	binary.NativeEndian.PutUint32(message[0:], uint32(id))
+	binary.NativeEndian.PutUint32(message[8:], uint32(len(message)))
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i != -1 {
+		b = b[:i]
+	}
+	return string(b)
+}
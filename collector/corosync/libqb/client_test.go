@@ -0,0 +1,243 @@
+package libqb
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creates the files of a ring buffer like a libqb server would
+func createRingBuffer(t *testing.T, name string, words uint32) {
+	header := make([]byte, headerSize+4)
+	binary.NativeEndian.PutUint32(header[wordSizeOffset:], words)
+	binary.NativeEndian.PutUint32(header[refCountOffset:], 1)
+	require.NoError(t, os.WriteFile(name+"-header", header, 0600))
+	require.NoError(t, os.WriteFile(name+"-data", make([]byte, words*4), 0600))
+}
+
+// a minimal libqb server, accepting a single connection and handling it with the given function
+func serve(t *testing.T, errno int32, handle func(request, response, event *ringBuffer)) string {
+	dir := t.TempDir()
+	address := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		request := make([]byte, connectionRequestSize)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+
+		response := make([]byte, connectionResponseSize)
+		putHeader(response, msgAuthenticate)
+		binary.NativeEndian.PutUint32(response[16:], uint32(errno))
+		binary.NativeEndian.PutUint32(response[ResponseHeaderSize:], ipcTypeShm)
+		var rings []*ringBuffer
+		for i, name := range []string{"request", "response", "event"} {
+			path := filepath.Join(dir, name)
+			createRingBuffer(t, path, 1024)
+			copy(response[ResponseHeaderSize+3*8+i*pathMax:], path)
+			rb, err := openRingBuffer(path)
+			if err != nil {
+				return
+			}
+			rings = append(rings, rb)
+		}
+		if _, err := conn.Write(response); err != nil {
+			return
+		}
+
+		handle(rings[0], rings[1], rings[2])
+
+		// wait for the client to disconnect before releasing the ring buffers
+		io.Copy(io.Discard, conn)
+		for _, rb := range rings {
+			// we release the creator reference as well
+			atomic.AddInt32(rb.refCount(), -1)
+			rb.close()
+		}
+	}()
+
+	return address
+}
+
+func TestClientRequest(t *testing.T) {
+	address := serve(t, 0, func(request, response, event *ringBuffer) {
+		var chunk []byte
+		for chunk == nil {
+			chunk, _, _ = request.read()
+			request.semWait(waitInterval)
+		}
+		// let the client block on the response semaphore
+		time.Sleep(10 * time.Millisecond)
+		reply := make([]byte, ResponseHeaderSize+len(chunk)-RequestHeaderSize)
+		putHeader(reply, int32(binary.NativeEndian.Uint32(chunk)))
+		binary.NativeEndian.PutUint32(reply[16:], 1)
+		copy(reply[ResponseHeaderSize:], chunk[RequestHeaderSize:])
+		event.write(reply)
+		response.write(reply)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	client, err := dial(ctx, address)
+	require.NoError(t, err)
+	defer client.Close()
+
+	response, err := client.Request(ctx, 3, []byte("hello world"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), response.Id)
+	assert.Equal(t, int32(1), response.Error)
+	assert.Equal(t, []byte("hello world"), response.Payload)
+
+	event, err := client.ReadEvent(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, response, event)
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	address := serve(t, 0, func(request, response, event *ringBuffer) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	client, err := dial(ctx, address)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Request(ctx, 0, nil)
+	assert.EqualError(t, err, "gave up waiting for a response: context deadline exceeded")
+}
+
+func TestClientRequestCancel(t *testing.T) {
+	address := serve(t, 0, func(request, response, event *ringBuffer) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := dial(ctx, address)
+	require.NoError(t, err)
+	defer client.Close()
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = client.ReadEvent(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestClientDialCancel(t *testing.T) {
+	dir := t.TempDir()
+	address := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", address)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// the server accepts the connection, but never answers the handshake
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = dial(ctx, address)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClientConnectionRefused(t *testing.T) {
+	address := serve(t, -int32(13), func(request, response, event *ringBuffer) {})
+
+	_, err := dial(context.Background(), address)
+	assert.EqualError(t, err, "could not set up the connection: connection refused: permission denied")
+}
+
+func TestClientCloseReleasesRingBuffers(t *testing.T) {
+	done := make(chan bool)
+	address := serve(t, 0, func(request, response, event *ringBuffer) {
+		done <- true
+	})
+
+	client, err := dial(context.Background(), address)
+	require.NoError(t, err)
+	<-done
+	headerPath := client.request.headerPath
+	client.Close()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(headerPath)
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+}
+
+func TestRingBufferWrapsAround(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rb")
+	createRingBuffer(t, name, 64)
+	rb, err := openRingBuffer(name)
+	require.NoError(t, err)
+	defer rb.close()
+
+	// 7 bytes chunks take 4 words each, header included, so we go around the 64 words buffer a few times
+	for i := 0; i < 50; i++ {
+		chunk := []byte{byte(i), 1, 2, 3, 4, 5, 6}
+		require.NoError(t, rb.write(chunk))
+		read, ok, err := rb.read()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, chunk, read)
+	}
+
+	_, ok, err := rb.read()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRingBufferSemWaitWakesUp(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rb")
+	createRingBuffer(t, name, 64)
+	rb, err := openRingBuffer(name)
+	require.NoError(t, err)
+	defer rb.close()
+
+	time.AfterFunc(10*time.Millisecond, func() { rb.write([]byte("hello")) })
+	start := time.Now()
+	rb.semWait(5 * time.Second)
+	assert.Less(t, time.Since(start), time.Second)
+
+	chunk, ok, err := rb.read()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("hello"), chunk)
+}
+
+func TestRingBufferFull(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rb")
+	createRingBuffer(t, name, 64)
+	rb, err := openRingBuffer(name)
+	require.NoError(t, err)
+	defer rb.close()
+
+	assert.EqualError(t, rb.write(make([]byte, 256)), "not enough space in the ring buffer")
+}
+
+func TestShmPath(t *testing.T) {
+	assert.Equal(t, "/dev/shm/qb-cmap-request-1-2-3-header", shmPath("qb-cmap-request-1-2-3-header"))
+	assert.Equal(t, "/dev/shm/qb-1-2-3-abc/qb-request-cmap-header", shmPath("/dev/shm/qb-1-2-3-abc/qb-request-cmap-header"))
+}
@@ -0,0 +1,260 @@
+package libqb
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// the shared header of a libqb ring buffer is laid out as follows (see struct qb_ringbuffer_shared_s in libqb)
+/*
+	volatile uint32_t write_pt;
+	volatile uint32_t read_pt;
+	uint32_t word_size;
+	char hdr_path[PATH_MAX];
+	char data_path[PATH_MAX];
+	int32_t ref_count;
+	sem_t posix_sem;
+	char user_data[1];
+*/
+const (
+	pathMax        = 4096
+	writePtOffset  = 0
+	readPtOffset   = 4
+	wordSizeOffset = 8
+	refCountOffset = 12 + 2*pathMax
+	// sem_t is 8 bytes aligned, and so is the offset following ref_count
+	semOffset = refCountOffset + 4
+	// the smallest header we accept, i.e. up to the end of the 32 bytes sem_t
+	headerSize = semOffset + 32
+)
+
+// each chunk of data in the ring buffer is preceded by two words: its size in bytes and a magic number marking its state
+const (
+	chunkHeaderWords = 2
+	chunkMagic       = 0xA1A1A1A1
+	chunkMagicDead   = 0xD0D0D0D0
+	chunkMagicAlloc  = 0xA1A1A100
+	// libqb always leaves some room between the write and the read pointers, so that they never overlap
+	chunkMargin = 4 * (chunkHeaderWords + 1 + 16)
+)
+
+// ringBuffer is the client side of a libqb shared memory ring buffer, created by the server
+type ringBuffer struct {
+	headerPath string
+	dataPath   string
+	header     []byte
+	data       []byte
+	words      uint32
+}
+
+// shmPath resolves the ring buffer file names sent by libqb servers, which are relative to /dev/shm unless absolute
+func shmPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join("/dev/shm", name)
+}
+
+func openRingBuffer(name string) (rb *ringBuffer, err error) {
+	rb = &ringBuffer{
+		headerPath: shmPath(name + "-header"),
+		dataPath:   shmPath(name + "-data"),
+	}
+
+	rb.header, err = mmapFile(rb.headerPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not map ring buffer header")
+	}
+	if len(rb.header) < headerSize {
+		rb.unmap()
+		return nil, errors.Errorf("ring buffer header %s is too small", rb.headerPath)
+	}
+
+	rb.data, err = mmapFile(rb.dataPath)
+	if err != nil {
+		rb.unmap()
+		return nil, errors.Wrap(err, "could not map ring buffer data")
+	}
+
+	rb.words = atomic.LoadUint32(rb.headerWord(wordSizeOffset))
+	if rb.words == 0 || uint64(rb.words)*4 > uint64(len(rb.data)) {
+		rb.unmap()
+		return nil, errors.Errorf("ring buffer %s has an invalid size", name)
+	}
+
+	// the files are unlinked by whoever releases the last reference to them, be it the server or the client
+	atomic.AddInt32(rb.refCount(), 1)
+
+	return rb, nil
+}
+
+func mmapFile(path string) ([]byte, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, errors.Errorf("%s is empty", path)
+	}
+
+	return syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func (rb *ringBuffer) close() {
+	if atomic.AddInt32(rb.refCount(), -1) == 0 {
+		os.Remove(rb.headerPath)
+		os.Remove(rb.dataPath)
+	}
+	rb.unmap()
+}
+
+func (rb *ringBuffer) unmap() {
+	if rb.header != nil {
+		syscall.Munmap(rb.header)
+		rb.header = nil
+	}
+	if rb.data != nil {
+		syscall.Munmap(rb.data)
+		rb.data = nil
+	}
+}
+
+func (rb *ringBuffer) headerWord(offset int) *uint32 {
+	return (*uint32)(unsafe.Pointer(&rb.header[offset]))
+}
+
+func (rb *ringBuffer) refCount() *int32 {
+	return (*int32)(unsafe.Pointer(&rb.header[refCountOffset]))
+}
+
+func (rb *ringBuffer) dataWord(index uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&rb.data[(index%rb.words)*4]))
+}
+
+// the position of the chunk following the one at the given position
+func (rb *ringBuffer) step(pointer uint32, size uint32) uint32 {
+	pointer += chunkHeaderWords + size/4
+	if size%4 != 0 {
+		pointer++
+	}
+	return pointer % rb.words
+}
+
+func (rb *ringBuffer) spaceFree(writePt uint32, readPt uint32) uint64 {
+	var words uint32
+	switch {
+	case writePt > readPt:
+		words = readPt - writePt + rb.words - 1
+	case writePt < readPt:
+		words = readPt - writePt - 1
+	default:
+		words = rb.words - 1
+	}
+	return uint64(words) * 4
+}
+
+// write appends a chunk to the ring buffer, and posts its semaphore like libqb does
+func (rb *ringBuffer) write(chunk []byte) error {
+	writePt := atomic.LoadUint32(rb.headerWord(writePtOffset))
+	readPt := atomic.LoadUint32(rb.headerWord(readPtOffset))
+	if rb.spaceFree(writePt, readPt) < uint64(len(chunk)+chunkMargin) {
+		return errors.New("not enough space in the ring buffer")
+	}
+
+	atomic.StoreUint32(rb.dataWord(writePt+1), chunkMagicAlloc)
+	rb.copyIn(writePt+chunkHeaderWords, chunk)
+	atomic.StoreUint32(rb.dataWord(writePt), uint32(len(chunk)))
+	atomic.StoreUint32(rb.headerWord(writePtOffset), rb.step(writePt, uint32(len(chunk))))
+	atomic.StoreUint32(rb.dataWord(writePt+1), chunkMagic)
+
+	rb.semPost()
+	return nil
+}
+
+// read consumes the chunk at the read pointer, if there is one
+func (rb *ringBuffer) read() ([]byte, bool, error) {
+	readPt := atomic.LoadUint32(rb.headerWord(readPtOffset))
+	if atomic.LoadUint32(rb.dataWord(readPt+1)) != chunkMagic {
+		return nil, false, nil
+	}
+
+	size := atomic.LoadUint32(rb.dataWord(readPt))
+	if uint64(size) > uint64(rb.words)*4 {
+		return nil, false, errors.Errorf("invalid ring buffer chunk size %d", size)
+	}
+	chunk := make([]byte, size)
+	rb.copyOut(readPt+chunkHeaderWords, chunk)
+
+	atomic.StoreUint32(rb.dataWord(readPt+1), chunkMagicDead)
+	atomic.StoreUint32(rb.headerWord(readPtOffset), rb.step(readPt, size))
+
+	rb.semTryWait()
+	return chunk, true, nil
+}
+
+// libqb maps the data twice in a row, so that chunks can wrap around the end of the buffer; we only map it once, so we wrap manually
+func (rb *ringBuffer) copyIn(index uint32, b []byte) {
+	n := copy(rb.data[(index%rb.words)*4:rb.words*4], b)
+	copy(rb.data, b[n:])
+}
+
+func (rb *ringBuffer) copyOut(index uint32, b []byte) {
+	n := copy(b, rb.data[(index%rb.words)*4:rb.words*4])
+	copy(b[n:], rb.data)
+}
+
+// glibc keeps the value of a process-shared semaphore in the low 32 bits of its first 64 bits word,
+// and the number of waiters in the high ones
+func (rb *ringBuffer) sem() *uint64 {
+	return (*uint64)(unsafe.Pointer(&rb.header[semOffset]))
+}
+
+func (rb *ringBuffer) semValue() *uint32 {
+	if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+		// big endian
+		return rb.headerWord(semOffset + 4)
+	}
+	return rb.headerWord(semOffset)
+}
+
+func (rb *ringBuffer) semPost() {
+	if atomic.AddUint64(rb.sem(), 1)>>32 > 0 {
+		futexWake(rb.semValue())
+	}
+}
+
+// semWait blocks until the semaphore is posted, or for the given time at most, without decrementing it;
+// the waiter is registered first, so that a post happening right after the value is checked still wakes it up
+func (rb *ringBuffer) semWait(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	atomic.AddUint64(rb.sem(), 1<<32)
+	defer atomic.AddUint64(rb.sem(), ^uint64(1<<32-1))
+	if uint32(atomic.LoadUint64(rb.sem())) != 0 {
+		return
+	}
+	futexWait(rb.semValue(), 0, timeout)
+}
+
+func (rb *ringBuffer) semTryWait() {
+	for {
+		sem := atomic.LoadUint64(rb.sem())
+		if uint32(sem) == 0 || atomic.CompareAndSwapUint64(rb.sem(), sem, sem-1) {
+			return
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package libqb
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	futexWaitOp = 0
+	futexWakeOp = 1
+)
+
+// blocks on the given process-shared futex, as long as it holds the given value, until it's woken up or the timeout expires;
+// it may also return early, e.g. when interrupted by a signal, so the callers must check what they are waiting for again
+func futexWait(addr *uint32, value uint32, timeout time.Duration) {
+	ts := syscall.NsecToTimespec(timeout.Nanoseconds())
+	syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexWaitOp, uintptr(value), uintptr(unsafe.Pointer(&ts)), 0, 0)
+}
+
+// wakes up one of the processes blocked on the given process-shared futex
+func futexWake(addr *uint32) {
+	syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexWakeOp, 1, 0, 0, 0)
+}
+
+// libqb servers authenticate their clients with the credentials attached to the messages by the kernel
+func setPassCred(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package libqb
+
+import "time"
+
+// without futexes, the waiters just poll
+func futexWait(addr *uint32, value uint32, timeout time.Duration) {
+	time.Sleep(min(timeout, time.Millisecond))
+}
+
+func futexWake(addr *uint32) {}
+
+func setPassCred(fd uintptr) error {
+	return nil
+}
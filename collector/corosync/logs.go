@@ -0,0 +1,334 @@
+package corosync
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
+)
+
+// JournalLogSource is the log source that reads the corosync logs from the systemd journal, instead of a file
+const JournalLogSource = "journal"
+
+const (
+	logPollInterval  = time.Second
+	logRetryInterval = 10 * time.Second
+)
+
+// the log messages counted by type; they are the earliest signs of trouble with the totem protocol
+var logEventPatterns = map[string]string{
+	"processor_failed":   "A processor failed, forming new configuration",
+	"token_not_received": "Token has not been received in",
+	"retransmit":         "Retransmit List",
+}
+
+// the arguments of journalctl to follow the new entries of the corosync unit, in the export format
+var journalArgs = []string{"--follow", "--lines=0", "--output=export", "--unit=corosync.service"}
+
+// logWatcher follows the corosync logs and keeps track of the events found in them
+type logWatcher struct {
+	sync.Mutex
+	source         string
+	journalctlPath string
+	executor       collector.Executor
+	clock          clock.Clock
+	logger         log.Logger
+	counts         map[string]uint64
+	last           map[string]time.Time
+	tail           *fileTail
+	stop           chan struct{}
+}
+
+// newLogWatcher creates a watcher of the given log source; journalctl is only run, via the executor, when the source is the journal
+func newLogWatcher(source string, journalctlPath string, executor collector.Executor, clock clock.Clock, logger log.Logger) (*logWatcher, error) {
+	if source == JournalLogSource {
+		err := collector.CheckExecutables(journalctlPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not find journalctl")
+		}
+	}
+
+	w := &logWatcher{
+		source:         source,
+		journalctlPath: journalctlPath,
+		executor:       executor,
+		clock:          clock,
+		logger:         logger,
+		counts:         make(map[string]uint64),
+		last:           make(map[string]time.Time),
+		stop:           make(chan struct{}),
+	}
+	for event := range logEventPatterns {
+		w.counts[event] = 0
+	}
+	// the log file is opened right away, so that no line appended after the watcher is created is missed
+	if source != JournalLogSource {
+		w.tail = openFileTail(source, logger)
+	}
+	return w, nil
+}
+
+// watch follows the log source until the watcher is closed, recovering from any error
+func (w *logWatcher) watch() {
+	if w.source == JournalLogSource {
+		for {
+			err := w.followJournal()
+			// journalctl is killed when the watcher is closed, which is no failure
+			select {
+			case <-w.stop:
+				return
+			default:
+			}
+			level.Warn(w.logger).Log("msg", "could not follow the corosync journal", "err", err)
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(logRetryInterval):
+			}
+		}
+	}
+
+	w.followFile(logPollInterval)
+}
+
+// close stops the watcher; it must only be called once
+func (w *logWatcher) close() {
+	close(w.stop)
+}
+
+// record matches a log message against the known events, and counts it
+func (w *logWatcher) record(message string, at time.Time) {
+	for event, pattern := range logEventPatterns {
+		if !strings.Contains(message, pattern) {
+			continue
+		}
+		w.Lock()
+		w.counts[event]++
+		if at.After(w.last[event]) {
+			w.last[event] = at
+		}
+		w.Unlock()
+	}
+}
+
+// snapshot returns a copy of the event counts and of their last occurrence times
+func (w *logWatcher) snapshot() (map[string]uint64, map[string]time.Time) {
+	w.Lock()
+	defer w.Unlock()
+	counts := make(map[string]uint64, len(w.counts))
+	for event, count := range w.counts {
+		counts[event] = count
+	}
+	last := make(map[string]time.Time, len(w.last))
+	for event, at := range w.last {
+		last[event] = at
+	}
+	return counts, last
+}
+
+// fileTail reads the lines appended to a plain text log file, like `tail -F` does, following truncation and rotation
+type fileTail struct {
+	path    string
+	file    *os.File
+	reader  *bufio.Reader
+	partial string
+	logger  log.Logger
+}
+
+// openFileTail opens a log file at its end, so that only the lines appended from now on are read; when the file
+// can't be opened yet, it's read from its start once it can, since it then only contains new lines
+func openFileTail(path string, logger log.Logger) *fileTail {
+	t := &fileTail{path: path, logger: logger}
+	t.open()
+	if t.file != nil {
+		_, err := t.file.Seek(0, io.SeekEnd)
+		if err != nil {
+			level.Debug(t.logger).Log("msg", "could not seek the end of the corosync log", "err", err)
+		}
+	}
+	return t
+}
+
+func (t *fileTail) open() {
+	file, err := os.Open(t.path)
+	if err != nil {
+		level.Debug(t.logger).Log("msg", "could not open the corosync log", "err", err)
+		return
+	}
+	t.file = file
+	t.reader = bufio.NewReader(file)
+	t.partial = ""
+}
+
+// readLines passes each complete line appended since the last call to the handler, then checks whether the file
+// was rotated or truncated, so that the next call reads the new content from its start
+func (t *fileTail) readLines(handle func(string)) {
+	if t.file == nil {
+		// after a rotation the new file only contains new lines, so we read it from its start
+		t.open()
+		if t.file == nil {
+			return
+		}
+	}
+
+	for {
+		line, err := t.reader.ReadString('\n')
+		if err != nil {
+			// the last line may still be incomplete
+			t.partial += line
+			break
+		}
+		handle(t.partial + line)
+		t.partial = ""
+	}
+
+	current, err := t.file.Stat()
+	latest, statErr := os.Stat(t.path)
+	offset, seekErr := t.file.Seek(0, io.SeekCurrent)
+	switch {
+	case err != nil || statErr != nil || !os.SameFile(current, latest):
+		// rotated or removed: we reopen the file from its start
+		t.close()
+	case seekErr == nil && latest.Size() < offset-int64(t.reader.Buffered()):
+		// truncated: we start over from the beginning
+		t.file.Seek(0, io.SeekStart)
+		t.reader.Reset(t.file)
+		t.partial = ""
+	}
+}
+
+func (t *fileTail) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// followFile reads the lines appended to the log file at the given interval, until the watcher is closed;
+// plain text logs have no year nor time zone in their timestamps, so the events are dated when they are read
+func (w *logWatcher) followFile(interval time.Duration) {
+	defer w.tail.close()
+	for {
+		w.tail.readLines(func(line string) {
+			w.record(line, w.clock.Now())
+		})
+
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// followJournal follows the journal of the corosync unit via journalctl, in the export format, until it exits
+func (w *logWatcher) followJournal() error {
+	// journalctl is stopped by cancelling its context, since it may run with other privileges, e.g. via sudo
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := w.executor.Command(ctx, w.journalctlPath, journalArgs...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-w.stop:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	err = parseJournalExport(stdout, func(entry map[string]string) {
+		at := w.clock.Now()
+		if usec, err := strconv.ParseInt(entry["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+			at = time.UnixMicro(usec)
+		}
+		w.record(entry["MESSAGE"], at)
+	})
+	// journalctl keeps running when the export can't be parsed, so it's stopped before waiting for it
+	cancel()
+	cmd.Wait()
+	if err != nil {
+		return err
+	}
+	return errors.New("journalctl exited")
+}
+
+// parseJournalExport reads the entries of the journal export format, calling the handler after each one;
+// entries are separated by an empty line, and their fields are either `KEY=value` lines or, for binary values,
+// a `KEY` line followed by the value length as a 64 bit little endian integer, the value, and a new line
+func parseJournalExport(r io.Reader, handle func(map[string]string)) error {
+	reader := bufio.NewReader(r)
+	entry := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			if len(entry) > 0 {
+				handle(entry)
+			}
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if line == "" {
+			if len(entry) > 0 {
+				handle(entry)
+				entry = make(map[string]string)
+			}
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok {
+			entry[key] = value
+			continue
+		}
+
+		if !isJournalFieldName(line) {
+			return errors.Errorf("invalid journal export line %q", line)
+		}
+		var length uint64
+		err = binary.Read(reader, binary.LittleEndian, &length)
+		if err != nil {
+			return errors.Wrapf(err, "could not read the length of the %s field", line)
+		}
+		value := make([]byte, length+1)
+		_, err = io.ReadFull(reader, value)
+		if err != nil {
+			return errors.Wrapf(err, "could not read the %s field", line)
+		}
+		entry[line] = string(value[:length])
+	}
+}
+
+// journal field names only contain uppercase letters, digits and underscores
+func isJournalFieldName(name string) bool {
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return name != ""
+}
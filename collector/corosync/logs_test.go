@@ -0,0 +1,190 @@
+package corosync
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
+)
+
+func TestLogWatcherRecord(t *testing.T) {
+	w, err := newLogWatcher("", "", collector.DirectExecutor{}, &clock.StoppedClock{}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	at := time.Unix(1571403000, 0)
+	w.record("Oct 18 12:50:00 [2001] hana01 corosync notice  [TOTEM ] A processor failed, forming new configuration.", at)
+	w.record("Oct 18 12:50:00 [2001] hana01 corosync warning [MAIN  ] Token has not been received in 3750 ms", at)
+	w.record("Oct 18 12:50:01 [2001] hana01 corosync notice  [TOTEM ] Retransmit List: 29 2a", at.Add(time.Second))
+	w.record("Oct 18 12:50:02 [2001] hana01 corosync notice  [TOTEM ] Retransmit List: 2b", at.Add(2*time.Second))
+	w.record("Oct 18 12:50:03 [2001] hana01 corosync notice  [QUORUM] Members[2]: 1084783375 1084783376", at)
+
+	counts, last := w.snapshot()
+	assert.Equal(t, map[string]uint64{
+		"processor_failed":   1,
+		"token_not_received": 1,
+		"retransmit":         2,
+	}, counts)
+	assert.Equal(t, map[string]time.Time{
+		"processor_failed":   at,
+		"token_not_received": at,
+		"retransmit":         at.Add(2 * time.Second),
+	}, last)
+}
+
+func appendToFile(t *testing.T, path string, content string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func retransmits(w *logWatcher) func() bool {
+	return func() bool {
+		counts, _ := w.snapshot()
+		return counts["retransmit"] == 3
+	}
+}
+
+func TestLogWatcherFollowFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corosync.log")
+	// the existing content is skipped
+	appendToFile(t, path, "[TOTEM ] Retransmit List: 1\n")
+
+	w, err := newLogWatcher(path, "", collector.DirectExecutor{}, &clock.StoppedClock{}, log.NewNopLogger())
+	require.NoError(t, err)
+	go w.followFile(time.Millisecond)
+	defer w.close()
+
+	appendToFile(t, path, "[TOTEM ] Retransmit List: 2\n[TOTEM ] Retra")
+	appendToFile(t, path, "nsmit List: 3\n")
+
+	// rotation
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendToFile(t, path, "[TOTEM ] Retransmit List: 4\n")
+
+	assert.Eventually(t, retransmits(w), time.Second, time.Millisecond)
+
+	_, last := w.snapshot()
+	assert.Equal(t, clock.StoppedClock{}.Now(), last["retransmit"])
+}
+
+func TestLogWatcherFollowFileTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corosync.log")
+	appendToFile(t, path, strings.Repeat("[MAIN  ] Corosync Cluster Engine is starting\n", 10))
+
+	w, err := newLogWatcher(path, "", collector.DirectExecutor{}, &clock.StoppedClock{}, log.NewNopLogger())
+	require.NoError(t, err)
+	go w.followFile(time.Millisecond)
+	defer w.close()
+
+	require.NoError(t, os.Truncate(path, 0))
+	appendToFile(t, path, "[TOTEM ] Retransmit List: 1\n[TOTEM ] Retransmit List: 2\n[TOTEM ] Retransmit List: 3\n")
+
+	assert.Eventually(t, retransmits(w), time.Second, time.Millisecond)
+}
+
+func TestLogWatcherFollowFileCreatedLater(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corosync.log")
+
+	w, err := newLogWatcher(path, "", collector.DirectExecutor{}, &clock.StoppedClock{}, log.NewNopLogger())
+	require.NoError(t, err)
+	go w.followFile(time.Millisecond)
+	defer w.close()
+
+	// the file did not exist when the watcher was created, so all of its content is new
+	appendToFile(t, path, "[TOTEM ] Retransmit List: 1\n[TOTEM ] Retransmit List: 2\n[TOTEM ] Retransmit List: 3\n")
+
+	assert.Eventually(t, retransmits(w), time.Second, time.Millisecond)
+}
+
+func TestLogWatcherFollowJournal(t *testing.T) {
+	w, err := newLogWatcher(JournalLogSource, "../../test/fake_journalctl.sh", collector.DirectExecutor{}, &clock.StoppedClock{}, log.NewNopLogger())
+	require.NoError(t, err)
+	stopped := make(chan struct{})
+	go func() {
+		w.watch()
+		close(stopped)
+	}()
+
+	assert.Eventually(t, func() bool {
+		counts, _ := w.snapshot()
+		return counts["processor_failed"] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	_, last := w.snapshot()
+	assert.Equal(t, time.Unix(1571403000, 0), last["processor_failed"])
+
+	// closing the watcher kills journalctl, and stops following the journal right away
+	w.close()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the journal is still followed after closing the watcher")
+	}
+}
+
+func TestLogWatcherFollowJournalMalformed(t *testing.T) {
+	w, err := newLogWatcher(JournalLogSource, "../../test/fake_journalctl_malformed.sh", collector.DirectExecutor{}, &clock.StoppedClock{}, log.NewNopLogger())
+	require.NoError(t, err)
+	defer w.close()
+
+	// journalctl keeps running after the malformed entry, and is killed instead of being waited for forever
+	errs := make(chan error)
+	go func() {
+		errs <- w.followJournal()
+	}()
+	select {
+	case err := <-errs:
+		assert.EqualError(t, err, `invalid journal export line "not an export entry"`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the journal is still followed after failing to parse it")
+	}
+}
+
+func TestParseJournalExport(t *testing.T) {
+	binaryMessage := "Token has not been received in 3750 ms\n"
+	length := make([]byte, 8)
+	binary.LittleEndian.PutUint64(length, uint64(len(binaryMessage)))
+
+	export := "__REALTIME_TIMESTAMP=1571403000000000\n" +
+		"_SYSTEMD_UNIT=corosync.service\n" +
+		"MESSAGE=[TOTEM ] A processor failed, forming new configuration.\n" +
+		"\n" +
+		"__REALTIME_TIMESTAMP=1571403001500000\n" +
+		"MESSAGE\n" + string(length) + binaryMessage + "\n" +
+		"_SYSTEMD_UNIT=corosync.service\n"
+
+	var entries []map[string]string
+	err := parseJournalExport(strings.NewReader(export), func(entry map[string]string) {
+		entries = append(entries, entry)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{
+			"__REALTIME_TIMESTAMP": "1571403000000000",
+			"_SYSTEMD_UNIT":        "corosync.service",
+			"MESSAGE":              "[TOTEM ] A processor failed, forming new configuration.",
+		},
+		{
+			"__REALTIME_TIMESTAMP": "1571403001500000",
+			"MESSAGE":              binaryMessage,
+			"_SYSTEMD_UNIT":        "corosync.service",
+		},
+	}, entries)
+}
+
+func TestParseJournalExportTruncatedBinaryField(t *testing.T) {
+	err := parseJournalExport(strings.NewReader("MESSAGE\n\x10\x00\x00\x00\x00\x00\x00\x00short"), func(map[string]string) {})
+
+	assert.EqualError(t, err, "could not read the MESSAGE field: unexpected EOF")
+}
@@ -1,24 +1,34 @@
 package corosync
 
 import (
+	"context"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
 type Parser interface {
-	Parse(cfgToolOutput []byte, quorumToolOutput []byte) (*Status, error)
+	Parse(ctx context.Context) (*Status, error)
 }
 
 type Status struct {
-	NodeId      string
-	RingId      string
-	Rings       []Ring
-	QuorumVotes QuorumVotes
-	Quorate     bool
-	Members     []Member
+	NodeId         string
+	RingId         string
+	RingSeq        uint64
+	Rings          []Ring
+	QuorumVotes    QuorumVotes
+	Quorate        bool
+	Members        []Member
+	RuntimeMembers []RuntimeMember
+	Nodes          []Node
+	Stats          Stats
 }
 
 type QuorumVotes struct {
@@ -42,13 +52,81 @@ type Member struct {
 	Local   bool
 }
 
-func NewParser() Parser {
-	return &defaultParser{}
+// RuntimeMember is a node that corosync has seen in the membership since it started,
+// as recorded in the `runtime.members.*` keys of the cmap database
+type RuntimeMember struct {
+	Id        string
+	Status    string
+	JoinCount uint64
+}
+
+// Node is a node configured in the corosync nodelist, as recorded in the `nodelist.node.*` keys of the cmap database
+type Node struct {
+	Id   string
+	Name string
+	// the address of the node on each ring, keyed by ring number
+	Addresses map[string]string
+}
+
+// Stats are the totem and service statistics kept by corosync in the cmap database
+type Stats struct {
+	// the totem single ring protocol statistics, keyed by name, e.g. `orf_token_rx`
+	Srp map[string]uint64
+	// the totem process groups statistics, keyed by name, e.g. `msg_queue_avail`
+	Pg   map[string]uint64
+	Ipcs IpcsStats
+}
+
+type IpcsStats struct {
+	Active uint64
+	Closed uint64
+	// the counters of each IPC connection, summed by the name of the client process, then keyed by name, e.g. `requests`
+	Processes map[string]map[string]uint64
 }
 
-type defaultParser struct{}
+// NewParser returns a Parser that runs the corosync command line tools and parses their output
+func NewParser(cfgToolPath string, quorumToolPath string, cmapctlPath string, executor collector.Executor) Parser {
+	return &defaultParser{cfgToolPath, quorumToolPath, cmapctlPath, executor}
+}
+
+type defaultParser struct {
+	cfgToolPath    string
+	quorumToolPath string
+	cmapctlPath    string
+	executor       collector.Executor
+}
+
+func (p *defaultParser) Parse(ctx context.Context) (*Status, error) {
+	// We suppress the exec errors because if any interface is faulty the tools will exit with code 1, but we still want to parse the output.
+	var cfgToolOutput, quorumToolOutput, cmapOutput, cmapStatsOutput []byte
+	workers := collector.NewWorkers(ctx, collector.DefaultParallelism)
+	workers.Go(func(ctx context.Context) error {
+		cfgToolOutput, _ = collector.RunCommand(ctx, p.executor, p.cfgToolPath, "-s")
+		return nil
+	})
+	workers.Go(func(ctx context.Context) error {
+		quorumToolOutput, _ = collector.RunCommand(ctx, p.executor, p.quorumToolPath, "-p")
+		return nil
+	})
+	workers.Go(func(ctx context.Context) error {
+		cmapOutput, _ = collector.RunCommand(ctx, p.executor, p.cmapctlPath)
+		return nil
+	})
+	// the stats map is not available in corosync < v2.99, where the statistics are in the main map instead
+	workers.Go(func(ctx context.Context) error {
+		cmapStatsOutput, _ = collector.RunCommand(ctx, p.executor, p.cmapctlPath, "-m", "stats")
+		return nil
+	})
+	workers.Wait()
+	// unlike the exit codes, a timeout can't be ignored, since the output is then truncated
+	if ctx.Err() != nil {
+		return nil, errors.Wrap(ctx.Err(), "corosync command line tools did not complete in time")
+	}
+
+	return p.parse(cfgToolOutput, quorumToolOutput, cmapOutput, cmapStatsOutput)
+}
 
-func (p *defaultParser) Parse(cfgToolOutput []byte, quorumToolOutput []byte) (*Status, error) {
+func (p *defaultParser) parse(cfgToolOutput []byte, quorumToolOutput []byte, cmapOutput []byte, cmapStatsOutput []byte) (*Status, error) {
 	status := &Status{}
 	var err error
 
@@ -62,6 +140,11 @@ func (p *defaultParser) Parse(cfgToolOutput []byte, quorumToolOutput []byte) (*S
 		return nil, errors.Wrap(err, "could not parse ring id and seq number in corosync-quorumtool output")
 	}
 
+	status.RingSeq, err = parseRingSeq(status.RingId)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse ring seq number in corosync-quorumtool output")
+	}
+
 	status.Quorate, err = parseQuorate(quorumToolOutput)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not parse quorate in corosync-quorumtool output")
@@ -79,9 +162,49 @@ func (p *defaultParser) Parse(cfgToolOutput []byte, quorumToolOutput []byte) (*S
 
 	status.Rings = parseRings(cfgToolOutput)
 
+	cmap := parseCmap(cmapOutput)
+
+	status.RuntimeMembers, err = parseRuntimeMembers(cmap)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse runtime members in corosync-cmapctl output")
+	}
+
+	status.Nodes = parseNodelist(cmap)
+	resolveNodeNames(status)
+
+	// corosync v2.99+ keeps the statistics in a dedicated cmap database, while older versions keep them in the main one
+	for key, value := range parseCmap(cmapStatsOutput) {
+		cmap[key] = value
+	}
+
+	status.Stats, err = parseStats(cmap)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse statistics in corosync-cmapctl output")
+	}
+
 	return status, nil
 }
 
+// NewFallbackParser returns a Parser that resorts to the fallback one whenever the primary one fails
+func NewFallbackParser(primary Parser, fallback Parser, logger log.Logger) Parser {
+	return &fallbackParser{primary, fallback, logger}
+}
+
+type fallbackParser struct {
+	primary  Parser
+	fallback Parser
+	logger   log.Logger
+}
+
+func (p *fallbackParser) Parse(ctx context.Context) (*Status, error) {
+	status, err := p.primary.Parse(ctx)
+	if err == nil {
+		return status, nil
+	}
+	level.Warn(p.logger).Log("msg", "falling back to the corosync command line tools", "err", err)
+	return p.fallback.Parse(ctx)
+}
+
 func parseNodeId(quorumToolOutput []byte) (string, error) {
 	nodeRe := regexp.MustCompile(`(?m)Node ID:\s+(\w+)`)
 	matches := nodeRe.FindSubmatch(quorumToolOutput)
@@ -102,7 +225,7 @@ func parseRingId(quorumToolOutput []byte) (string, error) {
 		Quorum provider:  corosync_votequorum
 		Nodes:            2
 		Node ID:          1084780051
-		Ring ID:          1084780051.44
+		Ring ID:          40a86e13.44
 		Quorate:          Yes
 	*/
 	// in corosync < v2.99 the line is slightly different:
@@ -124,6 +247,22 @@ func parseRingId(quorumToolOutput []byte) (string, error) {
 	return string(matches[1]), nil
 }
 
+// the ring id parsed by parseRingId is composed by an optional representative node id and the sequence number;
+// the sequence number increases on every membership change, so it is split out here to be used as a numeric value
+func parseRingSeq(ringId string) (uint64, error) {
+	// in corosync v2.99+ the ring id is printed as hexadecimal numbers, e.g. `1.2c`
+	if i := strings.LastIndex(ringId, "."); i != -1 {
+		return strconv.ParseUint(ringId[i+1:], 16, 64)
+	}
+
+	// in corosync < v2.99 the ring id is printed as decimal numbers, e.g. `1084780051/44`, or just `44` in corosync < v2.4
+	if i := strings.LastIndex(ringId, "/"); i != -1 {
+		return strconv.ParseUint(ringId[i+1:], 10, 64)
+	}
+
+	return strconv.ParseUint(ringId, 10, 64)
+}
+
 func parseQuorate(quorumToolOutput []byte) (bool, error) {
 	re := regexp.MustCompile(`(?m)Quorate:\s+(Yes|No)`)
 	matches := re.FindSubmatch(quorumToolOutput)
@@ -254,6 +393,287 @@ func parseMembers(quorumToolOutput []byte) (members []Member, err error) {
 	return members, nil
 }
 
+// parses the key-value pairs of the cmap database from this kind of output from corosync-cmapctl
+/*
+	runtime.members.1084783375.config_version (u64) = 0
+	runtime.members.1084783375.ip (str) = r(0) ip(10.0.0.1)
+	runtime.members.1084783375.join_count (u32) = 1
+	runtime.members.1084783375.status (str) = joined
+*/
+// the value type is discarded, and all the values are returned as strings
+func parseCmap(cmapOutput []byte) map[string]string {
+	re := regexp.MustCompile(`(?m)^(?P<key>[\w.:\-]+) \(\w+\) = (?P<value>.*)$`)
+	matches := re.FindAllSubmatch(cmapOutput, -1)
+	cmap := make(map[string]string, len(matches))
+	for _, match := range matches {
+		namedMatches := extractRENamedCaptureGroups(re, match)
+		cmap[namedMatches["key"]] = strings.TrimSpace(namedMatches["value"])
+	}
+	return cmap
+}
+
+// extracts the members seen by corosync from the `runtime.members.<node_id>.<attribute>` cmap keys
+func parseRuntimeMembers(cmap map[string]string) ([]RuntimeMember, error) {
+	membersById := make(map[string]*RuntimeMember)
+	for key, value := range cmap {
+		if !strings.HasPrefix(key, "runtime.members.") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(key, "runtime.members."), ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		member, ok := membersById[parts[0]]
+		if !ok {
+			member = &RuntimeMember{Id: parts[0]}
+			membersById[parts[0]] = member
+		}
+
+		switch parts[1] {
+		case "status":
+			member.Status = value
+		case "join_count":
+			joinCount, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not parse join count to uint64")
+			}
+			member.JoinCount = joinCount
+		}
+	}
+
+	members := make([]RuntimeMember, 0, len(membersById))
+	for _, member := range membersById {
+		members = append(members, *member)
+	}
+	// map iteration order is random, so we sort by id to keep the output stable
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Id < members[j].Id
+	})
+
+	return members, nil
+}
+
+// extracts the configured nodes from the `nodelist.node.<position>.<attribute>` cmap keys, e.g.:
+/*
+	nodelist.node.0.name (str) = hana01
+	nodelist.node.0.nodeid (u32) = 1
+	nodelist.node.0.ring0_addr (str) = 10.0.0.1
+	nodelist.node.0.ring1_addr (str) = 172.16.0.1
+*/
+// the node id is optional in corosync < v2.99, where it is generated from the first ring address,
+// so in that case we look it up in the runtime members addresses
+func parseNodelist(cmap map[string]string) []Node {
+	addressRe := regexp.MustCompile(`^ring(\d+)_addr$`)
+
+	nodesByPosition := make(map[string]*Node)
+	for key, value := range cmap {
+		if !strings.HasPrefix(key, "nodelist.node.") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(key, "nodelist.node."), ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		node, ok := nodesByPosition[parts[0]]
+		if !ok {
+			node = &Node{Addresses: make(map[string]string)}
+			nodesByPosition[parts[0]] = node
+		}
+
+		switch {
+		case parts[1] == "nodeid":
+			node.Id = value
+		case parts[1] == "name":
+			node.Name = value
+		case addressRe.MatchString(parts[1]):
+			node.Addresses[addressRe.FindStringSubmatch(parts[1])[1]] = value
+		}
+	}
+
+	nodes := make([]Node, 0, len(nodesByPosition))
+	for _, node := range nodesByPosition {
+		if node.Id == "" {
+			node.Id = findRuntimeMemberId(cmap, node.Addresses["0"])
+		}
+		nodes = append(nodes, *node)
+	}
+	// map iteration order is random, so we sort by id to keep the output stable
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Id < nodes[j].Id
+	})
+
+	return nodes
+}
+
+// finds the id of the runtime member with the given address on the first ring, from this kind of cmap key:
+/*
+	runtime.members.1084783375.ip (str) = r(0) ip(10.0.0.1) r(1) ip(172.16.0.1)
+*/
+func findRuntimeMemberId(cmap map[string]string, address string) string {
+	if address == "" {
+		return ""
+	}
+	for key, value := range cmap {
+		if !strings.HasPrefix(key, "runtime.members.") || !strings.HasSuffix(key, ".ip") {
+			continue
+		}
+		if strings.Contains(value, "r(0) ip("+address+")") {
+			return strings.TrimSuffix(strings.TrimPrefix(key, "runtime.members."), ".ip")
+		}
+	}
+	return ""
+}
+
+// the names configured in the nodelist take precedence over the member names printed by the command line tools,
+// which may be addresses; nodes without a configured name take the member name, or their first ring address, like corosync does
+func resolveNodeNames(status *Status) {
+	for i := range status.Nodes {
+		node := &status.Nodes[i]
+		for j := range status.Members {
+			member := &status.Members[j]
+			if member.Id != node.Id {
+				continue
+			}
+			switch {
+			case node.Name != "":
+				member.Name = node.Name
+			case member.Name != "":
+				node.Name = member.Name
+			}
+		}
+		if node.Name == "" {
+			node.Name = node.Addresses["0"]
+		}
+	}
+}
+
+// the ipcs counters we sum up for each client process
+var ipcsCounters = map[string]bool{
+	"requests":           true,
+	"responses":          true,
+	"dispatched":         true,
+	"invalid_request":    true,
+	"overload":           true,
+	"send_retries":       true,
+	"recv_retries":       true,
+	"flow_control_count": true,
+}
+
+// extracts the statistics from the following cmap keys:
+/*
+	stats.srp.orf_token_rx (u64) = 2782
+	stats.pg.msg_queue_avail (u32) = 0
+	stats.ipcs.global.active (u64) = 9
+	stats.ipcs.service0.2037.0x55b4a4c0f2e0.procname (str) = pacemakerd
+	stats.ipcs.service0.2037.0x55b4a4c0f2e0.requests (u64) = 20
+*/
+// in corosync < v2.99 the same statistics are found with different keys:
+/*
+	runtime.totem.pg.mrp.srp.orf_token_rx (u64) = 2782
+	runtime.totem.pg.msg_queue_avail (u32) = 0
+	runtime.connections.active (u64) = 9
+	runtime.connections.pacemakerd:2037:0x55b4a4c0f2e0.name (str) = pacemakerd
+	runtime.connections.pacemakerd:2037:0x55b4a4c0f2e0.requests (u64) = 20
+*/
+func parseStats(cmap map[string]string) (stats Stats, err error) {
+	stats.Srp, err = parseCmapNumbers(cmap, "stats.srp.", "runtime.totem.pg.mrp.srp.")
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Pg, err = parseCmapNumbers(cmap, "stats.pg.", "runtime.totem.pg.")
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Ipcs, err = parseIpcsStats(cmap)
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// returns the numeric values of the keys directly below any of the given prefixes, keyed by their last segment
+func parseCmapNumbers(cmap map[string]string, prefixes ...string) (map[string]uint64, error) {
+	numbers := make(map[string]uint64)
+	for key, value := range cmap {
+		for _, prefix := range prefixes {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			name := strings.TrimPrefix(key, prefix)
+			// we skip nested keys, like runtime.totem.pg.mrp.srp.members.*
+			if strings.Contains(name, ".") {
+				continue
+			}
+			number, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not parse %s to uint64", key)
+			}
+			numbers[name] = number
+		}
+	}
+	return numbers, nil
+}
+
+func parseIpcsStats(cmap map[string]string) (ipcs IpcsStats, err error) {
+	globals, err := parseCmapNumbers(cmap, "stats.ipcs.global.", "runtime.connections.")
+	if err != nil {
+		return ipcs, err
+	}
+	ipcs.Active = globals["active"]
+	ipcs.Closed = globals["closed"]
+
+	// first, we group the keys of each connection, e.g. `stats.ipcs.service0.2037.0x55b4a4c0f2e0`
+	connections := make(map[string]map[string]string)
+	for key, value := range cmap {
+		var connection string
+		switch {
+		case strings.HasPrefix(key, "stats.ipcs.service"):
+			connection = strings.TrimPrefix(key, "stats.ipcs.")
+		case strings.HasPrefix(key, "runtime.connections."):
+			connection = strings.TrimPrefix(key, "runtime.connections.")
+		default:
+			continue
+		}
+		i := strings.LastIndex(connection, ".")
+		if i == -1 {
+			continue
+		}
+		if connections[connection[:i]] == nil {
+			connections[connection[:i]] = make(map[string]string)
+		}
+		connections[connection[:i]][connection[i+1:]] = value
+	}
+
+	// then we sum up the counters of each connection by client process name
+	ipcs.Processes = make(map[string]map[string]uint64)
+	for _, connection := range connections {
+		process, ok := connection["procname"]
+		if !ok {
+			process = connection["name"]
+		}
+		if ipcs.Processes[process] == nil {
+			ipcs.Processes[process] = make(map[string]uint64)
+		}
+		for name, value := range connection {
+			if !ipcsCounters[name] {
+				continue
+			}
+			number, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return ipcs, errors.Wrapf(err, "could not parse ipcs %s to uint64", name)
+			}
+			ipcs.Processes[process][name] += number
+		}
+	}
+
+	return ipcs, nil
+}
+
 // extracts (?P<name>) RegEx capture groups from a match, to avoid numerical index lookups
 func extractRENamedCaptureGroups(ringsRe *regexp.Regexp, match [][]byte) map[string]string {
 	namedMatches := make(map[string]string)
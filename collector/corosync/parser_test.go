@@ -8,7 +8,7 @@ import (
 )
 
 func TestParse(t *testing.T) {
-	p := NewParser()
+	p := &defaultParser{}
 
 	cfgToolOutput := []byte(`Printing link status.
 Local node ID 1084780051
@@ -25,7 +25,7 @@ Date:             Sun Sep 29 16:10:37 2019
 Quorum provider:  corosync_votequorum
 Nodes:            2
 Node ID:          1084780051
-Ring ID:          1084780051.44
+Ring ID:          40a86e13.44
 Quorate:          Yes
 
 Votequorum information
@@ -42,7 +42,24 @@ Membership information
 1084780051          1      NR dma-dog-hana01 (local)
 1084780052          1      A,V,NMW dma-dog-hana02`)
 
-	status, err := p.Parse(cfgToolOutput, quoromToolOutput)
+	cmapOutput := []byte(`runtime.members.1084780051.config_version (u64) = 0
+runtime.members.1084780051.ip (str) = r(0) ip(10.0.0.1) r(1) ip(172.16.0.1) 
+runtime.members.1084780051.join_count (u32) = 1
+runtime.members.1084780051.status (str) = joined
+runtime.members.1084780052.config_version (u64) = 0
+runtime.members.1084780052.ip (str) = r(0) ip(10.0.0.2) r(1) ip(172.16.0.2) 
+runtime.members.1084780052.join_count (u32) = 2
+runtime.members.1084780052.status (str) = left`)
+
+	cmapStatsOutput := []byte(`stats.ipcs.global.active (u64) = 1
+stats.ipcs.global.closed (u64) = 10
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.procname (str) = pacemakerd
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.requests (u64) = 20
+stats.pg.msg_queue_avail (u32) = 0
+stats.srp.mcast_retx (u64) = 3
+stats.srp.orf_token_rx (u64) = 2782`)
+
+	status, err := p.parse(cfgToolOutput, quoromToolOutput, cmapOutput, cmapStatsOutput)
 	assert.NoError(t, err)
 
 	rings := status.Rings
@@ -57,7 +74,8 @@ Membership information
 
 	assert.True(t, status.Quorate)
 	assert.Equal(t, "1084780051", status.NodeId)
-	assert.Equal(t, "1084780051.44", status.RingId)
+	assert.Equal(t, "40a86e13.44", status.RingId)
+	assert.EqualValues(t, 68, status.RingSeq)
 	assert.EqualValues(t, 232, status.QuorumVotes.ExpectedVotes)
 	assert.EqualValues(t, 22, status.QuorumVotes.HighestExpected)
 	assert.EqualValues(t, 21, status.QuorumVotes.TotalVotes)
@@ -75,6 +93,22 @@ Membership information
 	assert.Exactly(t, "A,V,NMW", members[1].Qdevice)
 	assert.False(t, members[1].Local)
 	assert.EqualValues(t, 1, members[1].Votes)
+
+	runtimeMembers := status.RuntimeMembers
+	assert.Len(t, runtimeMembers, 2)
+	assert.Exactly(t, "1084780051", runtimeMembers[0].Id)
+	assert.Exactly(t, "joined", runtimeMembers[0].Status)
+	assert.EqualValues(t, 1, runtimeMembers[0].JoinCount)
+	assert.Exactly(t, "1084780052", runtimeMembers[1].Id)
+	assert.Exactly(t, "left", runtimeMembers[1].Status)
+	assert.EqualValues(t, 2, runtimeMembers[1].JoinCount)
+
+	assert.EqualValues(t, 3, status.Stats.Srp["mcast_retx"])
+	assert.EqualValues(t, 2782, status.Stats.Srp["orf_token_rx"])
+	assert.EqualValues(t, 0, status.Stats.Pg["msg_queue_avail"])
+	assert.EqualValues(t, 1, status.Stats.Ipcs.Active)
+	assert.EqualValues(t, 10, status.Stats.Ipcs.Closed)
+	assert.EqualValues(t, 20, status.Stats.Ipcs.Processes["pacemakerd"]["requests"])
 }
 
 func TestParseRingIdInCorosyncV2_4(t *testing.T) {
@@ -135,6 +169,26 @@ Membership information
 	assert.Equal(t, "100", ringId)
 }
 
+func TestParseRingSeq(t *testing.T) {
+	ringIds := map[string]uint64{
+		"1.2c":          44,
+		"1084780051/44": 44,
+		"100":           100,
+	}
+	for ringId, expected := range ringIds {
+		t.Run(ringId, func(t *testing.T) {
+			ringSeq, err := parseRingSeq(ringId)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, ringSeq)
+		})
+	}
+}
+
+func TestParseRingSeqError(t *testing.T) {
+	_, err := parseRingSeq("1.foo")
+	assert.Error(t, err)
+}
+
 func TestParseFaultyRings(t *testing.T) {
 	cfgToolOutput := []byte(`Printing ring status.
 	Local node ID 16777226
@@ -323,3 +377,151 @@ Membership information
 	assert.True(t, members[1].Local)
 	assert.EqualValues(t, 1, members[1].Votes)
 }
+
+func TestParseCmap(t *testing.T) {
+	cmapOutput := []byte(`runtime.members.1.ip (str) = r(0) ip(10.0.0.1) 
+runtime.members.1.join_count (u32) = 1
+totem.cluster_name (str) = hacluster
+not a cmap line`)
+
+	cmap := parseCmap(cmapOutput)
+
+	assert.Len(t, cmap, 3)
+	assert.Equal(t, "r(0) ip(10.0.0.1)", cmap["runtime.members.1.ip"])
+	assert.Equal(t, "1", cmap["runtime.members.1.join_count"])
+	assert.Equal(t, "hacluster", cmap["totem.cluster_name"])
+}
+
+func TestParseRuntimeMembersUintError(t *testing.T) {
+	cmap := map[string]string{
+		"runtime.members.1.join_count": "10000000000000000000000000000000000000000000000",
+	}
+
+	_, err := parseRuntimeMembers(cmap)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not parse join count to uint64")
+	assert.Contains(t, err.Error(), "value out of range")
+}
+
+func TestParseStats(t *testing.T) {
+	cmap := parseCmap([]byte(`stats.ipcs.global.active (u64) = 3
+stats.ipcs.global.closed (u64) = 1002
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.procname (str) = pacemakerd
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.requests (u64) = 20
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.queueing (i32) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.procname (str) = pacemaker-based
+stats.ipcs.service2.2042.0x55b4a4c13a90.requests (u64) = 9
+stats.ipcs.service3.2045.0x55b4a4c13b80.procname (str) = pacemaker-based
+stats.ipcs.service3.2045.0x55b4a4c13b80.requests (u64) = 1
+stats.pg.msg_queue_avail (u32) = 0
+stats.pg.msg_reserved (u32) = 1
+stats.srp.mcast_retx (u64) = 3
+stats.srp.mtt_rx_token (u32) = 154`))
+
+	stats, err := parseStats(cmap)
+
+	assert.NoError(t, err)
+	assert.Len(t, stats.Srp, 2)
+	assert.EqualValues(t, 3, stats.Srp["mcast_retx"])
+	assert.EqualValues(t, 154, stats.Srp["mtt_rx_token"])
+	assert.Len(t, stats.Pg, 2)
+	assert.EqualValues(t, 0, stats.Pg["msg_queue_avail"])
+	assert.EqualValues(t, 1, stats.Pg["msg_reserved"])
+	assert.EqualValues(t, 3, stats.Ipcs.Active)
+	assert.EqualValues(t, 1002, stats.Ipcs.Closed)
+	assert.Len(t, stats.Ipcs.Processes, 2)
+	assert.Equal(t, map[string]uint64{"requests": 20}, stats.Ipcs.Processes["pacemakerd"])
+	assert.Equal(t, map[string]uint64{"requests": 10}, stats.Ipcs.Processes["pacemaker-based"])
+}
+
+func TestParseStatsInCorosyncV2(t *testing.T) {
+	cmap := parseCmap([]byte(`runtime.connections.active (u64) = 2
+runtime.connections.closed (u64) = 7
+runtime.connections.pacemakerd:2037:0x55b4a4c0f2e0.client_pid (u32) = 2037
+runtime.connections.pacemakerd:2037:0x55b4a4c0f2e0.name (str) = pacemakerd
+runtime.connections.pacemakerd:2037:0x55b4a4c0f2e0.requests (u64) = 20
+runtime.totem.pg.msg_queue_avail (u32) = 0
+runtime.totem.pg.mrp.srp.mcast_retx (u64) = 3
+runtime.totem.pg.mrp.srp.members.1084783375.join_count (u32) = 1`))
+
+	stats, err := parseStats(cmap)
+
+	assert.NoError(t, err)
+	assert.Len(t, stats.Srp, 1)
+	assert.EqualValues(t, 3, stats.Srp["mcast_retx"])
+	assert.Len(t, stats.Pg, 1)
+	assert.EqualValues(t, 0, stats.Pg["msg_queue_avail"])
+	assert.EqualValues(t, 2, stats.Ipcs.Active)
+	assert.EqualValues(t, 7, stats.Ipcs.Closed)
+	assert.Equal(t, map[string]uint64{"requests": 20}, stats.Ipcs.Processes["pacemakerd"])
+}
+
+func TestParseStatsUintError(t *testing.T) {
+	cmap := map[string]string{
+		"stats.srp.mcast_retx": "10000000000000000000000000000000000000000000000",
+	}
+
+	_, err := parseStats(cmap)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not parse stats.srp.mcast_retx to uint64")
+	assert.Contains(t, err.Error(), "value out of range")
+}
+
+func TestParseNodelist(t *testing.T) {
+	cmap := parseCmap([]byte(`nodelist.local_node_pos (u32) = 0
+nodelist.node.0.name (str) = hana01
+nodelist.node.0.nodeid (u32) = 1
+nodelist.node.0.ring0_addr (str) = 10.0.0.1
+nodelist.node.0.ring1_addr (str) = 172.16.0.1
+nodelist.node.1.nodeid (u32) = 2
+nodelist.node.1.ring0_addr (str) = 10.0.0.2
+nodelist.node.1.ring1_addr (str) = 172.16.0.2`))
+
+	nodes := parseNodelist(cmap)
+
+	assert.Equal(t, []Node{
+		{Id: "1", Name: "hana01", Addresses: map[string]string{"0": "10.0.0.1", "1": "172.16.0.1"}},
+		{Id: "2", Addresses: map[string]string{"0": "10.0.0.2", "1": "172.16.0.2"}},
+	}, nodes)
+}
+
+func TestParseNodelistWithoutNodeIdsInCorosyncV2(t *testing.T) {
+	cmap := parseCmap([]byte(`nodelist.node.0.ring0_addr (str) = 10.0.0.1
+nodelist.node.1.ring0_addr (str) = 10.0.0.2
+runtime.members.1084783375.ip (str) = r(0) ip(10.0.0.1) 
+runtime.members.1084783376.ip (str) = r(0) ip(10.0.0.2) `))
+
+	nodes := parseNodelist(cmap)
+
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, "1084783375", nodes[0].Id)
+	assert.Equal(t, "10.0.0.1", nodes[0].Addresses["0"])
+	assert.Equal(t, "1084783376", nodes[1].Id)
+	assert.Equal(t, "10.0.0.2", nodes[1].Addresses["0"])
+}
+
+func TestResolveNodeNames(t *testing.T) {
+	status := &Status{
+		Members: []Member{
+			{Id: "1", Name: "10.0.0.1"},
+			{Id: "2", Name: "hana02"},
+			{Id: "0", Name: "Qdevice"},
+		},
+		Nodes: []Node{
+			{Id: "1", Name: "hana01", Addresses: map[string]string{"0": "10.0.0.1"}},
+			{Id: "2", Addresses: map[string]string{"0": "10.0.0.2"}},
+			{Id: "3", Addresses: map[string]string{"0": "10.0.0.3"}},
+		},
+	}
+
+	resolveNodeNames(status)
+
+	assert.Equal(t, "hana01", status.Members[0].Name)
+	assert.Equal(t, "hana02", status.Members[1].Name)
+	assert.Equal(t, "Qdevice", status.Members[2].Name)
+	assert.Equal(t, "hana01", status.Nodes[0].Name)
+	assert.Equal(t, "hana02", status.Nodes[1].Name)
+	assert.Equal(t, "10.0.0.3", status.Nodes[2].Name)
+}
@@ -68,6 +68,16 @@ func (c *DefaultCollector) MakeCounterMetric(name string, value float64, labelVa
 	return c.makeMetric(name, value, prometheus.CounterValue, labelValues...)
 }
 
+// the buckets are keyed by upper bound, and their counts are cumulative, like in prometheus.MustNewConstHistogram
+func (c *DefaultCollector) MakeHistogramMetric(name string, count uint64, sum float64, buckets map[float64]uint64, labelValues ...string) prometheus.Metric {
+	desc := c.GetDescriptor(name)
+	metric := prometheus.MustNewConstHistogram(desc, count, sum, buckets, labelValues...)
+	if c.timestamps == true {
+		metric = prometheus.NewMetricWithTimestamp(c.Clock.Now(), metric)
+	}
+	return metric
+}
+
 func (c *DefaultCollector) makeMetric(name string, value float64, valueType prometheus.ValueType, labelValues ...string) prometheus.Metric {
 	desc := c.GetDescriptor(name)
 	metric := prometheus.MustNewConstMetric(desc, valueType, value, labelValues...)
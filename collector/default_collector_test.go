@@ -33,3 +33,21 @@ func TestMetricFactoryWithTimestamp(t *testing.T) {
 
 	assert.Equal(t, int64(clock.TEST_TIMESTAMP), *metricDto.TimestampMs)
 }
+
+func TestHistogramMetricFactory(t *testing.T) {
+	SUT := NewDefaultCollector("test", true, log.NewNopLogger())
+	SUT.Clock = &clock.StoppedClock{}
+	SUT.SetDescriptor("test_histogram", "", []string{"label"})
+
+	metric := SUT.MakeHistogramMetric("test_histogram", 3, 1.5, map[float64]uint64{0.1: 1, 1: 2}, "value")
+	metricDto := &dto.Metric{}
+	err := metric.Write(metricDto)
+
+	assert.Nil(t, err, "Unexpected error")
+
+	assert.Equal(t, SUT.GetDescriptor("test_histogram"), metric.Desc())
+	assert.Equal(t, uint64(3), metricDto.Histogram.GetSampleCount())
+	assert.Equal(t, 1.5, metricDto.Histogram.GetSampleSum())
+	assert.Len(t, metricDto.Histogram.Bucket, 2)
+	assert.Equal(t, int64(clock.TEST_TIMESTAMP), *metricDto.TimestampMs)
+}
@@ -0,0 +1,98 @@
+package drbd
+
+import (
+	"encoding/xml"
+	"net"
+)
+
+// the DRBD defaults of the options we report, for when they are not configured
+var drbdConfigDefaults = map[string]string{
+	"protocol":     "C",
+	"quorum":       "off",
+	"on-no-quorum": "suspend-io",
+	"fencing":      "dont-care",
+}
+
+// drbdConfig is the relevant part of the DRBD configuration, as dumped by `drbdadm dump-xml`
+type drbdConfig struct {
+	Common struct {
+		Sections []drbdConfigSection `xml:"section"`
+	} `xml:"common"`
+	Resources []drbdConfigResource `xml:"resource"`
+}
+
+type drbdConfigResource struct {
+	Name     string              `xml:"name,attr"`
+	Hosts    []drbdConfigHost    `xml:"host"`
+	Sections []drbdConfigSection `xml:"section"`
+}
+
+type drbdConfigHost struct {
+	Name string `xml:"name,attr"`
+	// there are no node ids in DRBD 8.4
+	NodeId  string `xml:"node-id"`
+	Address struct {
+		Family string `xml:"family,attr"`
+		Port   string `xml:"port,attr"`
+		Value  string `xml:",chardata"`
+	} `xml:"address"`
+}
+
+type drbdConfigSection struct {
+	Name    string `xml:"name,attr"`
+	Options []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"option"`
+}
+
+func parseDrbdConfig(configRaw []byte) (drbdConfig, error) {
+	var config drbdConfig
+	err := xml.Unmarshal(configRaw, &config)
+	return config, err
+}
+
+// option looks up an option of a resource, falling back to the common section and then to the DRBD default
+func (c drbdConfig) option(resource drbdConfigResource, sectionName string, name string) string {
+	for _, sections := range [][]drbdConfigSection{resource.Sections, c.Common.Sections} {
+		for _, section := range sections {
+			if section.Name != sectionName {
+				continue
+			}
+			for _, option := range section.Options {
+				if option.Name == name {
+					return option.Value
+				}
+			}
+		}
+	}
+	return drbdConfigDefaults[name]
+}
+
+// fencing is in the `net` section since DRBD 9, while it was in the `disk` section in DRBD 8.4
+func (c drbdConfig) fencing(resource drbdConfigResource) string {
+	fencing := c.option(resource, "net", "fencing")
+	if fencing == drbdConfigDefaults["fencing"] {
+		fencing = c.option(resource, "disk", "fencing")
+	}
+	return fencing
+}
+
+// peers returns the hosts of a resource other than the local one
+func (r drbdConfigResource) peers(hostname string) []drbdConfigHost {
+	var peers []drbdConfigHost
+	for _, host := range r.Hosts {
+		if host.Name != hostname {
+			peers = append(peers, host)
+		}
+	}
+	return peers
+}
+
+// address formats the address of a host like DRBD does, e.g. `192.168.1.10:7788` or `[fd00::10]:7788`
+func (h drbdConfigHost) address() string {
+	if h.Address.Value == "" {
+		return ""
+	}
+	return net.JoinHostPort(h.Address.Value, h.Address.Port)
+}
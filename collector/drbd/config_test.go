@@ -0,0 +1,83 @@
+package drbd
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrbdConfigParsing(t *testing.T) {
+	configRaw, err := exec.Command("../../test/fake_drbdadm.sh", "dump-xml").Output()
+	assert.NoError(t, err)
+
+	config, err := parseDrbdConfig(configRaw)
+
+	assert.NoError(t, err)
+	assert.Len(t, config.Resources, 2)
+	resource := config.Resources[0]
+	assert.Equal(t, "1-single-0", resource.Name)
+	assert.Equal(t, "C", config.option(resource, "net", "protocol"))
+	assert.Equal(t, "majority", config.option(resource, "options", "quorum"))
+	assert.Equal(t, "io-error", config.option(resource, "options", "on-no-quorum"))
+	assert.Equal(t, "resource-only", config.fencing(resource))
+
+	peers := resource.peers("SLE15-sp1-gm-drbd1145296-node2")
+	assert.Len(t, peers, 1)
+	assert.Equal(t, "SLE15-sp1-gm-drbd1145296-node1", peers[0].Name)
+	assert.Equal(t, "1", peers[0].NodeId)
+	assert.Equal(t, "192.168.124.10:7790", peers[0].address())
+
+	assert.Equal(t, "[fd00::10]:7791", config.Resources[1].peers("SLE15-sp1-gm-drbd1145296-node2")[0].address())
+}
+
+func TestDrbd84ConfigParsing(t *testing.T) {
+	configRaw := []byte(`
+<config file="/etc/drbd.conf">
+   <common>
+      <section name="disk">
+         <option name="fencing" value="resource-and-stonith"/>
+      </section>
+   </common>
+   <resource name="r0" conf-file-line="/etc/drbd.d/r0.res:1">
+      <host name="node1">
+         <volume vnr="0">
+            <device minor="0">/dev/drbd0</device>
+            <disk>/dev/vdb</disk>
+            <meta-disk>internal</meta-disk>
+         </volume>
+         <address family="ipv4" port="7788">10.0.0.1</address>
+      </host>
+      <host name="node2">
+         <volume vnr="0">
+            <device minor="0">/dev/drbd0</device>
+            <disk>/dev/vdb</disk>
+            <meta-disk>internal</meta-disk>
+         </volume>
+         <address family="ipv4" port="7788">10.0.0.2</address>
+      </host>
+   </resource>
+</config>
+`)
+
+	config, err := parseDrbdConfig(configRaw)
+
+	assert.NoError(t, err)
+	resource := config.Resources[0]
+	// the defaults apply to the options that are not configured
+	assert.Equal(t, "C", config.option(resource, "net", "protocol"))
+	assert.Equal(t, "off", config.option(resource, "options", "quorum"))
+	assert.Equal(t, "suspend-io", config.option(resource, "options", "on-no-quorum"))
+	assert.Equal(t, "resource-and-stonith", config.fencing(resource))
+
+	peers := resource.peers("node1")
+	assert.Len(t, peers, 1)
+	assert.Equal(t, "", peers[0].NodeId)
+	assert.Equal(t, "10.0.0.2:7788", peers[0].address())
+}
+
+func TestDrbdConfigParsingFailsOnInvalidXML(t *testing.T) {
+	_, err := parseDrbdConfig([]byte("drbdadm: no resources defined!"))
+
+	assert.Error(t, err)
+}
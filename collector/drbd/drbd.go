@@ -1,10 +1,10 @@
 package drbd
 
 import (
+	"context"
 	"encoding/json"
-	"os/exec"
-	"path/filepath"
-	"regexp"
+	"math"
+	"os"
 	"strconv"
 	"strings"
 
@@ -20,61 +20,134 @@ const subsystem = "drbd"
 
 // drbdStatus is for parsing relevant data we want to convert to metrics
 type drbdStatus struct {
-	Name    string `json:"name"`
-	Role    string `json:"role"`
-	Devices []struct {
-		Volume    int    `json:"volume"`
-		Written   int    `json:"written"`
-		Read      int    `json:"read"`
-		AlWrites  int    `json:"al-writes"`
-		BmWrites  int    `json:"bm-writes"`
-		UpPending int    `json:"upper-pending"`
-		LoPending int    `json:"lower-pending"`
-		Quorum    bool   `json:"quorum"`
-		DiskState string `json:"disk-state"`
-	} `json:"devices"`
-	Connections []struct {
-		PeerNodeID  int    `json:"peer-node-id"`
-		PeerRole    string `json:"peer-role"`
-		PeerDevices []struct {
-			Volume        int     `json:"volume"`
-			Received      int     `json:"received"`
-			Sent          int     `json:"sent"`
-			Pending       int     `json:"pending"`
-			Unacked       int     `json:"unacked"`
-			PeerDiskState string  `json:"peer-disk-state"`
-			PercentInSync float64 `json:"percent-in-sync"`
-		} `json:"peer_devices"`
-	} `json:"connections"`
-}
-
-func NewCollector(drbdSetupPath string, drbdSplitBrainPath string, timestamps bool, logger log.Logger) (*drbdCollector, error) {
+	Name        string           `json:"name"`
+	Role        string           `json:"role"`
+	Suspended   bool             `json:"suspended"`
+	Devices     []drbdDevice     `json:"devices"`
+	Connections []drbdConnection `json:"connections"`
+}
+
+type drbdDevice struct {
+	Volume    int    `json:"volume"`
+	Minor     int    `json:"minor"`
+	Size      int    `json:"size"`
+	Written   int    `json:"written"`
+	Read      int    `json:"read"`
+	AlWrites  int    `json:"al-writes"`
+	BmWrites  int    `json:"bm-writes"`
+	UpPending int    `json:"upper-pending"`
+	LoPending int    `json:"lower-pending"`
+	Quorum    bool   `json:"quorum"`
+	DiskState string `json:"disk-state"`
+	// the activity log is suspended, e.g. while its metadata is being written
+	AlSuspended bool `json:"al-suspended"`
+}
+
+type drbdConnection struct {
+	PeerNodeID      int              `json:"peer-node-id"`
+	PeerRole        string           `json:"peer-role"`
+	ConnectionState string           `json:"connection-state"`
+	Congested       bool             `json:"congested"`
+	PeerDevices     []drbdPeerDevice `json:"peer_devices"`
+}
+
+type drbdPeerDevice struct {
+	Volume           int     `json:"volume"`
+	ReplicationState string  `json:"replication-state"`
+	ResyncSuspended  string  `json:"resync-suspended"`
+	Received         int     `json:"received"`
+	Sent             int     `json:"sent"`
+	Pending          int     `json:"pending"`
+	Unacked          int     `json:"unacked"`
+	PeerDiskState    string  `json:"peer-disk-state"`
+	PercentInSync    float64 `json:"percent-in-sync"`
+	OutOfSync        int     `json:"out-of-sync"`
+	// the resync details are only present while resyncing; the amounts are in KiB, unless specified
+	HasSyncDetails bool `json:"has-sync-details"`
+	RsTotal        int  `json:"rs-total"`
+	RsDt0Ms        int  `json:"rs-dt0-ms"`
+	RsDb0Sectors   int  `json:"rs-db0-sectors"`
+}
+
+func NewCollector(drbdSetupPath string, drbdAdmPath string, drbdSplitBrainPath string, drbdReactorConfigPath string, systemctlPath string, followEvents bool, legacyGauges bool, executor collector.Executor, timestamps bool, logger log.Logger) (*drbdCollector, error) {
 	err := collector.CheckExecutables(drbdSetupPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
 	}
 
+	// the configuration is only there to give some context to the other metrics, so we can do without it
+	err = collector.CheckExecutables(drbdAdmPath)
+	if err != nil {
+		level.Warn(logger).Log("msg", "The DRBD configuration won't be collected", "err", err)
+		drbdAdmPath = ""
+	}
+
+	// drbdadm tells the local host apart from its peers by the node name, like this
+	hostname, _ := os.Hostname()
+
 	c := &drbdCollector{
 		collector.NewDefaultCollector(subsystem, timestamps, logger),
 		drbdSetupPath,
+		drbdAdmPath,
+		executor,
+		hostname,
 		drbdSplitBrainPath,
+		drbdReactorConfigPath,
+		systemctlPath,
+		legacyGauges,
+		nil,
+		"/sys",
+		"/proc",
+		"/dev",
 	}
 
 	c.SetDescriptor("resources", "The DRBD resources; 1 line per name, per volume", []string{"resource", "role", "volume", "disk_state"})
-	c.SetDescriptor("written", "KiB written to DRBD; 1 line per res, per volume", []string{"resource", "volume"})
-	c.SetDescriptor("read", "KiB read from DRBD; 1 line per res, per volume", []string{"resource", "volume"})
-	c.SetDescriptor("al_writes", "Writes to activity log; 1 line per res, per volume", []string{"resource", "volume"})
-	c.SetDescriptor("bm_writes", "Writes to bitmap; 1 line per res, per volume", []string{"resource", "volume"})
+	c.SetDescriptor("written_bytes_total", "Bytes written to DRBD; 1 line per res, per volume", []string{"resource", "volume"})
+	c.SetDescriptor("read_bytes_total", "Bytes read from DRBD; 1 line per res, per volume", []string{"resource", "volume"})
+	c.SetDescriptor("al_writes_total", "Writes to activity log; 1 line per res, per volume", []string{"resource", "volume"})
+	c.SetDescriptor("bm_writes_total", "Writes to bitmap; 1 line per res, per volume", []string{"resource", "volume"})
+	c.SetDescriptor("size_bytes", "The size of DRBD devices; 1 line per res, per volume", []string{"resource", "volume"})
+	c.SetDescriptor("suspended", "Whether the I/O of DRBD resources is suspended", []string{"resource"})
+	c.SetDescriptor("al_suspended", "Whether the activity log is suspended; 1 line per res, per volume", []string{"resource", "volume"})
 	c.SetDescriptor("upper_pending", "Upper pending; 1 line per res, per volume", []string{"resource", "volume"})
 	c.SetDescriptor("lower_pending", "Lower pending; 1 line per res, per volume", []string{"resource", "volume"})
 	c.SetDescriptor("quorum", "Quorum status per resource and per volume", []string{"resource", "volume"})
 	c.SetDescriptor("connections", "The DRBD resource connections; 1 line per per resource, per peer_node_id", []string{"resource", "peer_node_id", "peer_role", "volume", "peer_disk_state"})
 	c.SetDescriptor("connections_sync", "The in sync percentage value for DRBD resource connections", []string{"resource", "peer_node_id", "volume"})
-	c.SetDescriptor("connections_received", "KiB received per connection", []string{"resource", "peer_node_id", "volume"})
-	c.SetDescriptor("connections_sent", "KiB sent per connection", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections_received_bytes_total", "Bytes received per connection", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections_sent_bytes_total", "Bytes sent per connection", []string{"resource", "peer_node_id", "volume"})
 	c.SetDescriptor("connections_pending", "Pending value per connection", []string{"resource", "peer_node_id", "volume"})
 	c.SetDescriptor("connections_unacked", "Unacked value per connection", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections_state", "The state of DRBD resource connections; 1 line per resource, per peer_node_id", []string{"resource", "peer_node_id", "connection_state"})
+	c.SetDescriptor("connections_congested", "Whether DRBD resource connections are congested", []string{"resource", "peer_node_id"})
+	c.SetDescriptor("connections_replication_state", "The replication state of DRBD resource connections; 1 line per resource, per peer_node_id, per volume", []string{"resource", "peer_node_id", "volume", "replication_state"})
+	c.SetDescriptor("connections_resync_suspended", "Whether the resync of DRBD resource connections is suspended", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections_out_of_sync_bytes", "The amount of data out of sync per connection", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections_resync_done", "The resync progress percentage per connection, while resyncing", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections_resync_rate_bytes_per_second", "The recent resync rate per connection, while resyncing", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("connections_resync_eta_seconds", "The estimated time to finish the resync per connection, while resyncing", []string{"resource", "peer_node_id", "volume"})
+	c.SetDescriptor("resource_info", "The configuration of DRBD resources; 1 line per resource, per peer", []string{"resource", "protocol", "quorum", "on_no_quorum", "fencing", "peer_node_id", "peer_hostname", "peer_address"})
+	c.SetDescriptor("promoter_resources", "The DRBD resources managed by the drbd-reactor promoter", []string{"resource", "runner"})
+	c.SetDescriptor("promoter_start_units", "The units started by the drbd-reactor promoter where DRBD resources are promoted", []string{"resource", "unit"})
+	c.SetDescriptor("promoter_promoted", "Whether DRBD resources managed by the drbd-reactor promoter are promoted on this node", []string{"resource"})
+	c.SetDescriptor("promoter_target_state", "The state of the systemd targets of DRBD resources managed by the drbd-reactor promoter", []string{"resource", "state"})
 	c.SetDescriptor("split_brain", "Whether a split brain has been detected; 1 line per resource, per volume.", []string{"resource", "volume"})
+	c.SetDescriptor("split_brain_detected_timestamp_seconds", "When a split brain was detected, as a Unix timestamp; 1 line per resource, per volume.", []string{"resource", "volume"})
+
+	// the statistics used to be exported as gauges in KiB, which are kept for compatibility, on demand
+	if legacyGauges {
+		c.SetDescriptor("written", "KiB written to DRBD; 1 line per res, per volume", []string{"resource", "volume"})
+		c.SetDescriptor("read", "KiB read from DRBD; 1 line per res, per volume", []string{"resource", "volume"})
+		c.SetDescriptor("al_writes", "Writes to activity log; 1 line per res, per volume", []string{"resource", "volume"})
+		c.SetDescriptor("bm_writes", "Writes to bitmap; 1 line per res, per volume", []string{"resource", "volume"})
+		c.SetDescriptor("connections_received", "KiB received per connection", []string{"resource", "peer_node_id", "volume"})
+		c.SetDescriptor("connections_sent", "KiB sent per connection", []string{"resource", "peer_node_id", "volume"})
+	}
+
+	if followEvents {
+		c.followEvents(newEventsWatcher(drbdSetupPath, executor, logger))
+		go c.events.watch()
+	}
 
 	return c, nil
 }
@@ -82,35 +155,74 @@ func NewCollector(drbdSetupPath string, drbdSplitBrainPath string, timestamps bo
 type drbdCollector struct {
 	collector.DefaultCollector
 	drbdsetupPath      string
+	drbdadmPath        string
+	executor           collector.Executor
+	hostname           string
 	drbdSplitBrainPath string
+	reactorConfigPath  string
+	systemctlPath      string
+	legacyGauges       bool
+	events             *eventsWatcher
+	sysfsPath          string
+	procfsPath         string
+	devPath            string
 }
 
-func (c *drbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
+func (c *drbdCollector) CollectWithError(ctx context.Context, ch chan<- prometheus.Metric) error {
 	level.Debug(c.Logger).Log("msg", "Collecting DRBD metrics...")
 
 	c.recordDrbdSplitBrainMetric(ch)
+	c.recordEventsMetrics(ch)
 
-	drbdStatusRaw, err := exec.Command(c.drbdsetupPath, "status", "--json").Output()
+	// the configuration and the status are read concurrently, since they don't depend on each other
+	var drbdDev []drbdStatus
+	var drbd84 bool
+	workers := collector.NewWorkers(ctx, collector.DefaultParallelism)
+	workers.Go(func(ctx context.Context) error {
+		c.recordResourceInfo(ctx, ch)
+		return nil
+	})
+	workers.Go(func(ctx context.Context) (err error) {
+		drbdDev, drbd84, err = c.status(ctx)
+		return err
+	})
+	err := workers.Wait()
 	if err != nil {
-		return errors.Wrap(err, "drbdsetup command failed")
-	}
-	// populate structs and parse relevant info we will expose via metrics
-	drbdDev, err := parseDrbdStatus(drbdStatusRaw)
-	if err != nil {
-		return errors.Wrap(err, "could not parse drbdsetup status output")
+		return err
 	}
 
 	for _, resource := range drbdDev {
+		if resource.Suspended == true {
+			ch <- c.MakeGaugeMetric("suspended", float64(1), resource.Name)
+		} else {
+			ch <- c.MakeGaugeMetric("suspended", float64(0), resource.Name)
+		}
 		for _, device := range resource.Devices {
 			// the `resources` metric value is always 1, otherwise it's absent
 			ch <- c.MakeGaugeMetric("resources", float64(1), resource.Name, resource.Role, strconv.Itoa(device.Volume), strings.ToLower(device.DiskState))
-			ch <- c.MakeGaugeMetric("written", float64(device.Written), resource.Name, strconv.Itoa(device.Volume))
-			ch <- c.MakeGaugeMetric("read", float64(device.Read), resource.Name, strconv.Itoa(device.Volume))
-			ch <- c.MakeGaugeMetric("al_writes", float64(device.AlWrites), resource.Name, strconv.Itoa(device.Volume))
-			ch <- c.MakeGaugeMetric("bm_writes", float64(device.BmWrites), resource.Name, strconv.Itoa(device.Volume))
+			ch <- c.MakeCounterMetric("written_bytes_total", float64(device.Written)*1024, resource.Name, strconv.Itoa(device.Volume))
+			ch <- c.MakeCounterMetric("read_bytes_total", float64(device.Read)*1024, resource.Name, strconv.Itoa(device.Volume))
+			ch <- c.MakeCounterMetric("al_writes_total", float64(device.AlWrites), resource.Name, strconv.Itoa(device.Volume))
+			ch <- c.MakeCounterMetric("bm_writes_total", float64(device.BmWrites), resource.Name, strconv.Itoa(device.Volume))
+			if c.legacyGauges {
+				ch <- c.MakeGaugeMetric("written", float64(device.Written), resource.Name, strconv.Itoa(device.Volume))
+				ch <- c.MakeGaugeMetric("read", float64(device.Read), resource.Name, strconv.Itoa(device.Volume))
+				ch <- c.MakeGaugeMetric("al_writes", float64(device.AlWrites), resource.Name, strconv.Itoa(device.Volume))
+				ch <- c.MakeGaugeMetric("bm_writes", float64(device.BmWrites), resource.Name, strconv.Itoa(device.Volume))
+			}
 			ch <- c.MakeGaugeMetric("upper_pending", float64(device.UpPending), resource.Name, strconv.Itoa(device.Volume))
 			ch <- c.MakeGaugeMetric("lower_pending", float64(device.LoPending), resource.Name, strconv.Itoa(device.Volume))
+			ch <- c.MakeGaugeMetric("size_bytes", float64(device.Size)*1024, resource.Name, strconv.Itoa(device.Volume))
+			if device.AlSuspended == true {
+				ch <- c.MakeGaugeMetric("al_suspended", float64(1), resource.Name, strconv.Itoa(device.Volume))
+			} else {
+				ch <- c.MakeGaugeMetric("al_suspended", float64(0), resource.Name, strconv.Itoa(device.Volume))
+			}
 
+			// there is no quorum in DRBD 8.4
+			if drbd84 {
+				continue
+			}
 			if device.Quorum == true {
 				ch <- c.MakeGaugeMetric("quorum", float64(1), resource.Name, strconv.Itoa(device.Volume))
 			} else {
@@ -123,6 +235,13 @@ func (c *drbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 		}
 		// a Resource can have multiple connection with different nodes
 		for _, conn := range resource.Connections {
+			ch <- c.MakeGaugeMetric("connections_state", float64(1), resource.Name, strconv.Itoa(conn.PeerNodeID), strings.ToLower(conn.ConnectionState))
+			if conn.Congested == true {
+				ch <- c.MakeGaugeMetric("connections_congested", float64(1), resource.Name, strconv.Itoa(conn.PeerNodeID))
+			} else {
+				ch <- c.MakeGaugeMetric("connections_congested", float64(0), resource.Name, strconv.Itoa(conn.PeerNodeID))
+			}
+
 			if len(conn.PeerDevices) == 0 {
 				level.Warn(c.Logger).Log("msg", "Could not retrieve any peer device info for connection "+resource.Name, "err", err)
 				continue
@@ -130,26 +249,69 @@ func (c *drbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
 			for _, peerDev := range conn.PeerDevices {
 				ch <- c.MakeGaugeMetric("connections", float64(1), resource.Name, strconv.Itoa(conn.PeerNodeID), conn.PeerRole, strconv.Itoa(peerDev.Volume), strings.ToLower(peerDev.PeerDiskState))
 				ch <- c.MakeGaugeMetric("connections_sync", float64(peerDev.PercentInSync), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
-				ch <- c.MakeGaugeMetric("connections_received", float64(peerDev.Received), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
-				ch <- c.MakeGaugeMetric("connections_sent", float64(peerDev.Sent), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				ch <- c.MakeCounterMetric("connections_received_bytes_total", float64(peerDev.Received)*1024, resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				ch <- c.MakeCounterMetric("connections_sent_bytes_total", float64(peerDev.Sent)*1024, resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				if c.legacyGauges {
+					ch <- c.MakeGaugeMetric("connections_received", float64(peerDev.Received), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+					ch <- c.MakeGaugeMetric("connections_sent", float64(peerDev.Sent), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				}
 				ch <- c.MakeGaugeMetric("connections_pending", float64(peerDev.Pending), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
 				ch <- c.MakeGaugeMetric("connections_unacked", float64(peerDev.Unacked), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				ch <- c.MakeGaugeMetric("connections_replication_state", float64(1), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume), strings.ToLower(peerDev.ReplicationState))
+
+				ch <- c.MakeGaugeMetric("connections_out_of_sync_bytes", float64(peerDev.OutOfSync)*1024, resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				if peerDev.HasSyncDetails && peerDev.RsTotal > 0 {
+					c.recordResyncProgress(resource.Name, conn.PeerNodeID, peerDev.Volume, peerDev.RsTotal, peerDev.OutOfSync, peerDev.RsDb0Sectors, peerDev.RsDt0Ms, ch)
+				}
+
+				// the resync is not suspended when this is `no`, otherwise it's the reason why it is, e.g. `user` or `dependency`
+				if peerDev.ResyncSuspended != "" && peerDev.ResyncSuspended != "no" {
+					ch <- c.MakeGaugeMetric("connections_resync_suspended", float64(1), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				} else {
+					ch <- c.MakeGaugeMetric("connections_resync_suspended", float64(0), resource.Name, strconv.Itoa(conn.PeerNodeID), strconv.Itoa(peerDev.Volume))
+				}
 			}
 		}
 	}
 
+	c.recordPromoterMetrics(ctx, drbdDev, ch)
+
 	return nil
 }
 
 func (c *drbdCollector) Collect(ch chan<- prometheus.Metric) {
 	level.Debug(c.Logger).Log("msg", "Collecting DRBD metrics...")
 
-	err := c.CollectWithError(ch)
+	err := c.CollectWithError(context.Background(), ch)
 	if err != nil {
 		level.Warn(c.Logger).Log("msg", c.GetSubsystem()+" collector scrape failed", "err", err)
 	}
 }
 
+// status reads the status of the DRBD resources from the source matching the version of the kernel module:
+// `drbdsetup status` in DRBD 9, or /proc/drbd in DRBD 8.4, which is legacy; it returns whether the latter was used
+func (c *drbdCollector) status(ctx context.Context) ([]drbdStatus, bool, error) {
+	// when the version can't be read, e.g. because the module is not loaded, drbdsetup will tell what's wrong
+	if version, err := drbdModuleMajorVersion(c.sysfsPath); err == nil && version < 9 {
+		drbdDev, err := readProcDrbd(c.procfsPath, c.sysfsPath, c.devPath)
+		if err != nil {
+			return nil, true, errors.Wrap(err, "could not read /proc/drbd")
+		}
+		return drbdDev, true, nil
+	}
+
+	drbdStatusRaw, err := collector.RunCommand(ctx, c.executor, c.drbdsetupPath, "status", "--json", "--statistics")
+	if err != nil {
+		return nil, false, errors.Wrap(err, "drbdsetup command failed")
+	}
+	// populate structs and parse relevant info we will expose via metrics
+	drbdDev, err := parseDrbdStatus(drbdStatusRaw)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "could not parse drbdsetup status output")
+	}
+	return drbdDev, false, nil
+}
+
 func parseDrbdStatus(statusRaw []byte) ([]drbdStatus, error) {
 	var drbdDevs []drbdStatus
 	err := json.Unmarshal(statusRaw, &drbdDevs)
@@ -159,21 +321,187 @@ func parseDrbdStatus(statusRaw []byte) ([]drbdStatus, error) {
 	return drbdDevs, nil
 }
 
+// record the progress of a resync like drbdsetup does: the rate is the one of the last few seconds, and the ETA assumes it stays the same
+func (c *drbdCollector) recordResyncProgress(resource string, peerNodeID int, volume int, totalKiB int, outOfSyncKiB int, recentSectors int, recentMs int, ch chan<- prometheus.Metric) {
+	labels := []string{resource, strconv.Itoa(peerNodeID), strconv.Itoa(volume)}
+
+	done := 100 * (1 - float64(outOfSyncKiB)/float64(totalKiB))
+	ch <- c.MakeGaugeMetric("connections_resync_done", math.Max(done, 0), labels...)
+
+	if recentMs <= 0 {
+		return
+	}
+	// sectors are always 512 bytes in DRBD
+	rate := float64(recentSectors) * 512 / (float64(recentMs) / 1000)
+	ch <- c.MakeGaugeMetric("connections_resync_rate_bytes_per_second", rate, labels...)
+	if rate > 0 {
+		ch <- c.MakeGaugeMetric("connections_resync_eta_seconds", float64(outOfSyncKiB)*1024/rate, labels...)
+	}
+}
+
+// Close stops following the DRBD events, if they are followed
+func (c *drbdCollector) Close() error {
+	if c.events != nil {
+		c.events.close()
+	}
+	return nil
+}
+
+// followEvents registers the metrics of the state transitions counted by an events watcher
+func (c *drbdCollector) followEvents(events *eventsWatcher) {
+	c.SetDescriptor("events_up", "Whether the DRBD events are being followed", nil)
+	c.SetDescriptor("events_restarts_total", "The number of times drbdsetup events2 was restarted after exiting", nil)
+	c.SetDescriptor("role_changes_total", "The number of role changes of DRBD resources", []string{"resource"})
+	c.SetDescriptor("disk_state_changes_total", "The number of disk state changes of DRBD devices", []string{"resource", "volume"})
+	c.SetDescriptor("connection_losses_total", "The number of times DRBD connections were lost", []string{"resource", "peer_node_id"})
+	c.SetDescriptor("split_brain_events_total", "The number of split brains detected by DRBD", []string{"resource"})
+	c.events = events
+}
+
+func (c *drbdCollector) recordEventsMetrics(ch chan<- prometheus.Metric) {
+	if c.events == nil {
+		return
+	}
+	events := c.events.snapshot()
+
+	if events.Up {
+		ch <- c.MakeGaugeMetric("events_up", float64(1))
+	} else {
+		ch <- c.MakeGaugeMetric("events_up", float64(0))
+	}
+	ch <- c.MakeCounterMetric("events_restarts_total", float64(events.Restarts))
+	for key, count := range events.RoleChanges {
+		ch <- c.MakeCounterMetric("role_changes_total", float64(count), key.resource)
+	}
+	for key, count := range events.DiskStateChanges {
+		ch <- c.MakeCounterMetric("disk_state_changes_total", float64(count), key.resource, key.volume)
+	}
+	for key, count := range events.ConnectionLosses {
+		ch <- c.MakeCounterMetric("connection_losses_total", float64(count), key.resource, key.peerNodeId)
+	}
+	for key, count := range events.SplitBrains {
+		ch <- c.MakeCounterMetric("split_brain_events_total", float64(count), key.resource)
+	}
+}
+
+func (c *drbdCollector) recordPromoterMetrics(ctx context.Context, drbdDev []drbdStatus, ch chan<- prometheus.Metric) {
+	if c.reactorConfigPath == "" {
+		return
+	}
+	// drbd-reactor is optional, so there is nothing to report without its configuration
+	if _, err := os.Stat(c.reactorConfigPath); os.IsNotExist(err) {
+		return
+	}
+	resources, err := readReactorConfig(c.reactorConfigPath)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not read the drbd-reactor configuration", "err", err)
+		return
+	}
+
+	roles := make(map[string]string)
+	for _, resource := range drbdDev {
+		roles[resource.Name] = resource.Role
+	}
+
+	for name, resource := range resources {
+		ch <- c.MakeGaugeMetric("promoter_resources", float64(1), name, resource.Runner)
+		for _, unit := range resource.Start {
+			ch <- c.MakeGaugeMetric("promoter_start_units", float64(1), name, unit)
+		}
+		if roles[name] == "Primary" {
+			ch <- c.MakeGaugeMetric("promoter_promoted", float64(1), name)
+		} else {
+			ch <- c.MakeGaugeMetric("promoter_promoted", float64(0), name)
+		}
+	}
+
+	// only the systemd runner has a target
+	states, err := promoterTargetStates(ctx, c.executor, c.systemctlPath, systemdResources(resources))
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not query the state of the drbd-reactor promoter targets", "err", err)
+		return
+	}
+	for name, state := range states {
+		ch <- c.MakeGaugeMetric("promoter_target_state", float64(1), name, state)
+	}
+}
+
+func (c *drbdCollector) recordResourceInfo(ctx context.Context, ch chan<- prometheus.Metric) {
+	if c.drbdadmPath == "" {
+		return
+	}
+	configRaw, err := collector.RunCommand(ctx, c.executor, c.drbdadmPath, "dump-xml")
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "drbdadm dump-xml command failed", "err", err)
+		return
+	}
+	config, err := parseDrbdConfig(configRaw)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not parse drbdadm dump-xml output", "err", err)
+		return
+	}
+
+	for _, resource := range config.Resources {
+		labels := []string{
+			resource.Name,
+			config.option(resource, "net", "protocol"),
+			config.option(resource, "options", "quorum"),
+			config.option(resource, "options", "on-no-quorum"),
+			config.fencing(resource),
+		}
+		peers := resource.peers(c.hostname)
+		// the resource is reported even without peers, for the sake of its configuration
+		if len(peers) == 0 {
+			ch <- c.MakeGaugeMetric("resource_info", float64(1), append(labels, "", "", "")...)
+		}
+		for _, peer := range peers {
+			nodeId := peer.NodeId
+			if nodeId == "" {
+				nodeId = "0"
+			}
+			ch <- c.MakeGaugeMetric("resource_info", float64(1), append(labels, nodeId, peer.Name, peer.address())...)
+		}
+	}
+}
+
 func (c *drbdCollector) recordDrbdSplitBrainMetric(ch chan<- prometheus.Metric) {
 	// look for files created by the DRBD split brain hook
-	files, _ := filepath.Glob(c.drbdSplitBrainPath + "/drbd-split-brain-detected-*")
-
-	// prepare some pattern matching
-	re := regexp.MustCompile(`drbd-split-brain-detected-(?P<resource>[\w-]+)-(?P<volume>[\w-]+)`)
+	markers, err := ListSplitBrainMarkers(c.drbdSplitBrainPath)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "Could not list the DRBD split brain markers", "err", err)
+		return
+	}
 
 	// for each of these files, we extract the name of the resource end volume from its name and record the metric
-	for _, f := range files {
-		// matches[0] will be the whole file name, matches[1] the resource, matches[2] the volume
-		matches := re.FindStringSubmatch(f)
-		if matches == nil {
-			continue
-		}
+	for _, marker := range markers {
+		ch <- c.MakeGaugeMetric("split_brain", float64(1), marker.Resource, marker.Volume)
+		ch <- c.MakeGaugeMetric("split_brain_detected_timestamp_seconds", float64(marker.DetectedAt.UnixNano())/1e9, marker.Resource, marker.Volume)
+	}
+}
 
-		ch <- c.MakeGaugeMetric("split_brain", float64(1), matches[1], matches[2])
+// Commands returns the commands the collector runs, with their arguments, e.g. to allow them in a sudoers policy;
+// the systemctl one depends on the resources managed by drbd-reactor, so they must be updated whenever these change
+func Commands(drbdSetupPath string, drbdAdmPath string, drbdReactorConfigPath string, systemctlPath string, followEvents bool) ([][]string, error) {
+	commands := [][]string{
+		{drbdSetupPath, "status", "--json", "--statistics"},
+		{drbdAdmPath, "dump-xml"},
+	}
+	if followEvents {
+		commands = append(commands, []string{drbdSetupPath, "events2", "--timestamps", "--statistics"})
+	}
+
+	if drbdReactorConfigPath == "" {
+		return commands, nil
+	}
+	if _, err := os.Stat(drbdReactorConfigPath); os.IsNotExist(err) {
+		return commands, nil
+	}
+	resources, err := readReactorConfig(drbdReactorConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if names := systemdResources(resources); len(names) > 0 {
+		commands = append(commands, append([]string{systemctlPath}, promoterTargetStatesArgs(names)...))
 	}
+	return commands, nil
 }
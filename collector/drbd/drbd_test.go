@@ -8,6 +8,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
 )
 
@@ -141,35 +142,40 @@ func TestDrbdParsing(t *testing.T) {
 	assert.Equal(t, 4, drbdDevs[0].Connections[0].PeerDevices[0].Unacked)
 	assert.Equal(t, 100.0, drbdDevs[0].Connections[0].PeerDevices[0].PercentInSync)
 	assert.Equal(t, 99.8, drbdDevs[1].Connections[0].PeerDevices[0].PercentInSync)
+	assert.Equal(t, "Connected", drbdDevs[0].Connections[0].ConnectionState)
+	assert.Equal(t, false, drbdDevs[0].Connections[0].Congested)
+	assert.Equal(t, "Established", drbdDevs[0].Connections[0].PeerDevices[0].ReplicationState)
+	assert.Equal(t, "no", drbdDevs[0].Connections[0].PeerDevices[0].ResyncSuspended)
 }
 
 func TestNewDrbdCollector(t *testing.T) {
-	_, err := NewCollector("../../test/fake_drbdsetup.sh", "splitbrainpath", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "splitbrainpath", "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Nil(t, err)
 }
 
 func TestNewDrbdCollectorChecksDrbdsetupExistence(t *testing.T) {
-	_, err := NewCollector("../../test/nonexistent", "splitbrainfake", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/nonexistent", "../../test/fake_drbdadm.sh", "splitbrainfake", "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewDrbdCollectorChecksDrbdsetupExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/dummy", "splibrainfake", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/dummy", "../../test/fake_drbdadm.sh", "splibrainfake", "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestDRBDCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "fake", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
+	collector.hostname = "SLE15-sp1-gm-drbd1145296-node2"
 	assertcustom.Metrics(t, collector, "drbd.metrics")
 }
 
 func TestDRBDSplitbrainCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/drbd-splitbrain", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "../../test/drbd-splitbrain", "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	expect := `
 	# HELP ha_cluster_drbd_split_brain Whether a split brain has been detected; 1 line per resource, per volume.
@@ -182,3 +188,35 @@ func TestDRBDSplitbrainCollector(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestDRBDCollectorWithLegacyGauges(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", "", "../../test/fake_systemctl.sh", false, true, collector.DirectExecutor{}, false, log.NewNopLogger())
+
+	expect := `
+	# HELP ha_cluster_drbd_connections_sent KiB sent per connection
+	# TYPE ha_cluster_drbd_connections_sent gauge
+	ha_cluster_drbd_connections_sent{peer_node_id="1",resource="1-single-0",volume="0"} 654
+	ha_cluster_drbd_connections_sent{peer_node_id="1",resource="1-single-1",volume="0"} 654
+	# HELP ha_cluster_drbd_connections_sent_bytes_total Bytes sent per connection
+	# TYPE ha_cluster_drbd_connections_sent_bytes_total counter
+	ha_cluster_drbd_connections_sent_bytes_total{peer_node_id="1",resource="1-single-0",volume="0"} 669696
+	ha_cluster_drbd_connections_sent_bytes_total{peer_node_id="1",resource="1-single-1",volume="0"} 669696
+	# HELP ha_cluster_drbd_written KiB written to DRBD; 1 line per res, per volume
+	# TYPE ha_cluster_drbd_written gauge
+	ha_cluster_drbd_written{resource="1-single-0",volume="0"} 123456
+	ha_cluster_drbd_written{resource="1-single-1",volume="0"} 123456
+	# HELP ha_cluster_drbd_written_bytes_total Bytes written to DRBD; 1 line per res, per volume
+	# TYPE ha_cluster_drbd_written_bytes_total counter
+	ha_cluster_drbd_written_bytes_total{resource="1-single-0",volume="0"} 1.26418944e+08
+	ha_cluster_drbd_written_bytes_total{resource="1-single-1",volume="0"} 1.26418944e+08
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect),
+		"ha_cluster_drbd_connections_sent",
+		"ha_cluster_drbd_connections_sent_bytes_total",
+		"ha_cluster_drbd_written",
+		"ha_cluster_drbd_written_bytes_total",
+	)
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,298 @@
+package drbd
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+const (
+	eventsMinBackoff = time.Second
+	eventsMaxBackoff = time.Minute
+)
+
+// the keys of the objects whose state transitions are counted
+type resourceKey struct {
+	resource string
+}
+
+type deviceKey struct {
+	resource string
+	volume   string
+}
+
+type connectionKey struct {
+	resource   string
+	peerNodeId string
+}
+
+// eventsSnapshot is a copy of the transition counters of an eventsWatcher
+type eventsSnapshot struct {
+	Up               bool
+	Restarts         uint64
+	RoleChanges      map[resourceKey]uint64
+	DiskStateChanges map[deviceKey]uint64
+	ConnectionLosses map[connectionKey]uint64
+	SplitBrains      map[resourceKey]uint64
+}
+
+// eventsWatcher follows the DRBD events via `drbdsetup events2`, keeping track of the current state of the resources,
+// so that it can count the state transitions happening between scrapes, which would be missed by polling
+type eventsWatcher struct {
+	sync.Mutex
+	drbdsetupPath string
+	executor      collector.Executor
+	logger        log.Logger
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	stop          chan struct{}
+
+	up          bool
+	restarts    uint64
+	roles       map[resourceKey]string
+	diskStates  map[deviceKey]string
+	connections map[connectionKey]string
+
+	roleChanges      map[resourceKey]uint64
+	diskStateChanges map[deviceKey]uint64
+	connectionLosses map[connectionKey]uint64
+	splitBrains      map[resourceKey]uint64
+}
+
+func newEventsWatcher(drbdsetupPath string, executor collector.Executor, logger log.Logger) *eventsWatcher {
+	return &eventsWatcher{
+		drbdsetupPath:    drbdsetupPath,
+		executor:         executor,
+		logger:           logger,
+		minBackoff:       eventsMinBackoff,
+		maxBackoff:       eventsMaxBackoff,
+		stop:             make(chan struct{}),
+		roles:            make(map[resourceKey]string),
+		diskStates:       make(map[deviceKey]string),
+		connections:      make(map[connectionKey]string),
+		roleChanges:      make(map[resourceKey]uint64),
+		diskStateChanges: make(map[deviceKey]uint64),
+		connectionLosses: make(map[connectionKey]uint64),
+		splitBrains:      make(map[resourceKey]uint64),
+	}
+}
+
+// watch follows the events until the watcher is closed, restarting drbdsetup with an exponential backoff whenever it exits;
+// the backoff is reset once a stream has delivered the initial state, i.e. drbdsetup is working again
+func (w *eventsWatcher) watch() {
+	backoff := w.minBackoff
+	for {
+		healthy, err := w.follow()
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		if healthy {
+			backoff = w.minBackoff
+		}
+		level.Warn(w.logger).Log("msg", "drbdsetup events2 exited, restarting it", "backoff", backoff, "err", err)
+
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(backoff):
+		}
+		w.Lock()
+		w.restarts++
+		w.Unlock()
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+func (w *eventsWatcher) close() {
+	close(w.stop)
+}
+
+// follow runs drbdsetup events2 until it exits, handling its events; it returns whether the initial state was received
+func (w *eventsWatcher) follow() (bool, error) {
+	// drbdsetup is stopped by cancelling its context, since it may run with other privileges, e.g. via sudo
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := w.executor.Command(ctx, w.drbdsetupPath, "events2", "--timestamps", "--statistics")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return false, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-w.stop:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	w.setUp(true)
+	defer w.setUp(false)
+
+	healthy := false
+	reader := bufio.NewReader(stdout)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line != "" {
+			// the initial state ends with an `exists -` line
+			if w.handle(line) {
+				healthy = true
+			}
+		}
+		if readErr != nil {
+			cmd.Wait()
+			if readErr != io.EOF {
+				return healthy, readErr
+			}
+			return healthy, errors.New("drbdsetup events2 exited")
+		}
+	}
+}
+
+func (w *eventsWatcher) setUp(up bool) {
+	w.Lock()
+	w.up = up
+	w.Unlock()
+}
+
+// handle processes an event line, like these:
+/*
+	2020-01-15T10:12:05.123456+01:00 exists resource name:r0 role:Secondary suspended:no
+	2020-01-15T10:12:05.123456+01:00 exists -
+	2020-01-15T10:13:42.654321+01:00 change connection name:r0 peer-node-id:1 conn-name:node1 connection:Connecting role:Unknown
+	2020-01-15T10:13:42.654321+01:00 call helper name:r0 peer-node-id:1 conn-name:node1 volume:0 helper:split-brain
+*/
+// the state of known objects is compared with the previous one even for `exists` events,
+// so that the transitions that happened while drbdsetup was being restarted are counted too;
+// it returns whether the line marks the end of the initial state
+func (w *eventsWatcher) handle(line string) bool {
+	fields := strings.Fields(line)
+	// the timestamp is the only field starting with a digit
+	if len(fields) > 0 && fields[0][0] >= '0' && fields[0][0] <= '9' {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return false
+	}
+	eventType, object := fields[0], fields[1]
+	if eventType == "exists" && object == "-" {
+		return true
+	}
+
+	properties := make(map[string]string)
+	for _, field := range fields[2:] {
+		if key, value, ok := strings.Cut(field, ":"); ok {
+			properties[key] = value
+		}
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	switch object {
+	case "resource":
+		key := resourceKey{properties["name"]}
+		if eventType == "destroy" {
+			delete(w.roles, key)
+			return false
+		}
+		if _, ok := w.roleChanges[key]; !ok {
+			w.roleChanges[key] = 0
+			w.splitBrains[key] = 0
+		}
+		if role, ok := properties["role"]; ok {
+			if previous, known := w.roles[key]; known && previous != role {
+				w.roleChanges[key]++
+			}
+			w.roles[key] = role
+		}
+	case "device":
+		key := deviceKey{properties["name"], properties["volume"]}
+		if eventType == "destroy" {
+			delete(w.diskStates, key)
+			return false
+		}
+		if _, ok := w.diskStateChanges[key]; !ok {
+			w.diskStateChanges[key] = 0
+		}
+		if disk, ok := properties["disk"]; ok {
+			if previous, known := w.diskStates[key]; known && previous != disk {
+				w.diskStateChanges[key]++
+			}
+			w.diskStates[key] = disk
+		}
+	case "connection":
+		key := connectionKey{properties["name"], properties["peer-node-id"]}
+		if _, ok := w.connectionLosses[key]; !ok {
+			w.connectionLosses[key] = 0
+		}
+		// a connection that is deleted while connected is lost as well
+		if eventType == "destroy" {
+			if w.connections[key] == "Connected" {
+				w.connectionLosses[key]++
+			}
+			delete(w.connections, key)
+			return false
+		}
+		if connection, ok := properties["connection"]; ok {
+			if w.connections[key] == "Connected" && connection != "Connected" {
+				w.connectionLosses[key]++
+			}
+			w.connections[key] = connection
+		}
+	case "helper":
+		if eventType == "call" && properties["helper"] == "split-brain" {
+			w.splitBrains[resourceKey{properties["name"]}]++
+		}
+	}
+
+	return false
+}
+
+// snapshot returns a copy of the transition counters
+func (w *eventsWatcher) snapshot() eventsSnapshot {
+	w.Lock()
+	defer w.Unlock()
+
+	s := eventsSnapshot{
+		Up:               w.up,
+		Restarts:         w.restarts,
+		RoleChanges:      make(map[resourceKey]uint64, len(w.roleChanges)),
+		DiskStateChanges: make(map[deviceKey]uint64, len(w.diskStateChanges)),
+		ConnectionLosses: make(map[connectionKey]uint64, len(w.connectionLosses)),
+		SplitBrains:      make(map[resourceKey]uint64, len(w.splitBrains)),
+	}
+	for key, count := range w.roleChanges {
+		s.RoleChanges[key] = count
+	}
+	for key, count := range w.diskStateChanges {
+		s.DiskStateChanges[key] = count
+	}
+	for key, count := range w.connectionLosses {
+		s.ConnectionLosses[key] = count
+	}
+	for key, count := range w.splitBrains {
+		s.SplitBrains[key] = count
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package drbd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+func TestEventsWatcherHandle(t *testing.T) {
+	w := newEventsWatcher("", collector.DirectExecutor{}, log.NewNopLogger())
+
+	assert.False(t, w.handle("exists resource name:r0 role:Secondary suspended:no"))
+	assert.False(t, w.handle("exists connection name:r0 peer-node-id:1 conn-name:node1 connection:Connected role:Primary"))
+	assert.False(t, w.handle("exists device name:r0 volume:0 minor:0 disk:UpToDate"))
+	assert.True(t, w.handle("exists -"))
+
+	w.handle("2020-01-15T10:13:42.654321+01:00 change connection name:r0 peer-node-id:1 connection:NetworkFailure")
+	w.handle("2020-01-15T10:13:43.654321+01:00 change connection name:r0 peer-node-id:1 connection:Connecting")
+	w.handle("2020-01-15T10:13:44.654321+01:00 change connection name:r0 peer-node-id:1 connection:Connected")
+	w.handle("2020-01-15T10:13:45.654321+01:00 destroy connection name:r0 peer-node-id:1")
+	w.handle("2020-01-15T10:13:45.654321+01:00 change device name:r0 volume:0 disk:Failed")
+	w.handle("2020-01-15T10:13:45.654321+01:00 change device name:r0 volume:0 quorum:no")
+	w.handle("2020-01-15T10:13:46.654321+01:00 change resource name:r0 role:Primary")
+	w.handle("2020-01-15T10:13:46.654321+01:00 change resource name:r0 suspended:no")
+	w.handle("2020-01-15T10:13:47.654321+01:00 call helper name:r0 peer-node-id:1 volume:0 helper:split-brain")
+	w.handle("2020-01-15T10:13:47.654321+01:00 response helper name:r0 peer-node-id:1 volume:0 helper:split-brain status:0")
+
+	s := w.snapshot()
+	assert.Equal(t, map[resourceKey]uint64{{"r0"}: 1}, s.RoleChanges)
+	assert.Equal(t, map[deviceKey]uint64{{"r0", "0"}: 1}, s.DiskStateChanges)
+	assert.Equal(t, map[connectionKey]uint64{{"r0", "1"}: 2}, s.ConnectionLosses)
+	assert.Equal(t, map[resourceKey]uint64{{"r0"}: 1}, s.SplitBrains)
+}
+
+func TestEventsWatcherCountsTransitionsAcrossRestarts(t *testing.T) {
+	w := newEventsWatcher("", collector.DirectExecutor{}, log.NewNopLogger())
+	w.handle("exists resource name:r0 role:Secondary")
+	w.handle("exists -")
+
+	// drbdsetup was restarted, and the resource was promoted in the meantime
+	w.handle("exists resource name:r0 role:Primary")
+
+	assert.Equal(t, uint64(1), w.snapshot().RoleChanges[resourceKey{"r0"}])
+}
+
+func TestEventsWatcherFollow(t *testing.T) {
+	w := newEventsWatcher("../../test/fake_drbdsetup.sh", collector.DirectExecutor{}, log.NewNopLogger())
+
+	healthy, err := w.follow()
+
+	assert.True(t, healthy)
+	assert.EqualError(t, err, "drbdsetup events2 exited")
+	s := w.snapshot()
+	assert.False(t, s.Up)
+	assert.Equal(t, uint64(1), s.ConnectionLosses[connectionKey{"1-single-0", "1"}])
+	assert.Equal(t, uint64(2), s.DiskStateChanges[deviceKey{"1-single-0", "0"}])
+}
+
+func TestEventsWatcherRestartsWithBackoff(t *testing.T) {
+	w := newEventsWatcher("../../test/fake_drbdsetup.sh", collector.DirectExecutor{}, log.NewNopLogger())
+	w.minBackoff = time.Millisecond
+	w.maxBackoff = 2 * time.Millisecond
+	go w.watch()
+	defer w.close()
+
+	assert.Eventually(t, func() bool {
+		return w.snapshot().Restarts >= 3
+	}, time.Second, time.Millisecond)
+}
+
+func TestDRBDCollectorCloseStopsEvents(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", "", "../../test/fake_systemctl.sh", true, false, collector.DirectExecutor{}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	assert.NoError(t, collector.Close())
+	select {
+	case <-collector.events.stop:
+	default:
+		t.Error("the events watcher was not stopped")
+	}
+}
+
+func TestDRBDCollectorWithEvents(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
+	// we follow the events synchronously, instead of starting the watcher
+	collector.followEvents(newEventsWatcher("../../test/fake_drbdsetup.sh", collector.executor, log.NewNopLogger()))
+	collector.events.follow()
+
+	expect := `
+	# HELP ha_cluster_drbd_connection_losses_total The number of times DRBD connections were lost
+	# TYPE ha_cluster_drbd_connection_losses_total counter
+	ha_cluster_drbd_connection_losses_total{peer_node_id="1",resource="1-single-0"} 1
+	# HELP ha_cluster_drbd_disk_state_changes_total The number of disk state changes of DRBD devices
+	# TYPE ha_cluster_drbd_disk_state_changes_total counter
+	ha_cluster_drbd_disk_state_changes_total{resource="1-single-0",volume="0"} 2
+	# HELP ha_cluster_drbd_events_restarts_total The number of times drbdsetup events2 was restarted after exiting
+	# TYPE ha_cluster_drbd_events_restarts_total counter
+	ha_cluster_drbd_events_restarts_total 0
+	# HELP ha_cluster_drbd_events_up Whether the DRBD events are being followed
+	# TYPE ha_cluster_drbd_events_up gauge
+	ha_cluster_drbd_events_up 0
+	# HELP ha_cluster_drbd_role_changes_total The number of role changes of DRBD resources
+	# TYPE ha_cluster_drbd_role_changes_total counter
+	ha_cluster_drbd_role_changes_total{resource="1-single-0"} 1
+	# HELP ha_cluster_drbd_split_brain_events_total The number of split brains detected by DRBD
+	# TYPE ha_cluster_drbd_split_brain_events_total counter
+	ha_cluster_drbd_split_brain_events_total{resource="1-single-0"} 1
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect),
+		"ha_cluster_drbd_connection_losses_total",
+		"ha_cluster_drbd_disk_state_changes_total",
+		"ha_cluster_drbd_events_restarts_total",
+		"ha_cluster_drbd_events_up",
+		"ha_cluster_drbd_role_changes_total",
+		"ha_cluster_drbd_split_brain_events_total",
+	)
+
+	assert.NoError(t, err)
+}
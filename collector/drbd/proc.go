@@ -0,0 +1,287 @@
+package drbd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// the replication states of DRBD 8.4, which reports them in place of the connection state while connected
+var procDrbdReplicationStates = map[string]bool{
+	"StartingSyncS": true, "StartingSyncT": true, "WFBitMapS": true, "WFBitMapT": true, "WFSyncUUID": true,
+	"SyncSource": true, "SyncTarget": true, "PausedSyncS": true, "PausedSyncT": true,
+	"VerifyS": true, "VerifyT": true, "Ahead": true, "Behind": true,
+}
+
+var (
+	// e.g. `[==>.................] sync'ed: 20.4% (8148/10236)M`
+	procDrbdSyncRe = regexp.MustCompile(`sync'ed:\s*[\d.]+% \((\d+)/(\d+)\)([KM])`)
+	// e.g. `finish: 0:02:01 speed: 68,752 (68,752) K/sec`
+	procDrbdSpeedRe = regexp.MustCompile(`speed: ([\d,]+) `)
+)
+
+// procDrbdVolume is a DRBD 8.4 volume, which /proc/drbd only knows by its minor number
+type procDrbdVolume struct {
+	Resource string
+	Volume   int
+}
+
+// drbdModuleMajorVersion reads the major version of the loaded DRBD kernel module, e.g. `8` for `8.4.11-1`
+func drbdModuleMajorVersion(sysfsPath string) (int, error) {
+	raw, err := os.ReadFile(filepath.Join(sysfsPath, "module", "drbd", "version"))
+	if err != nil {
+		return 0, err
+	}
+	major, _, _ := strings.Cut(strings.TrimSpace(string(raw)), ".")
+	version, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse the DRBD module version '%s'", strings.TrimSpace(string(raw)))
+	}
+	return version, nil
+}
+
+// procDrbdVolumes maps the minor numbers to the resources and volumes they belong to, following the symlinks that udev
+// creates for DRBD 8.4 devices: either `drbd/by-res/<resource>/<volume>`, or `drbd/by-res/<resource>` for volume 0 only
+func procDrbdVolumes(devPath string) map[int]procDrbdVolume {
+	volumes := make(map[int]procDrbdVolume)
+	byRes := filepath.Join(devPath, "drbd", "by-res")
+	entries, err := os.ReadDir(byRes)
+	if err != nil {
+		return volumes
+	}
+
+	addVolume := func(link string, resource string, volume int) {
+		target, err := os.Readlink(link)
+		if err != nil {
+			return
+		}
+		minor, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(target), "drbd"))
+		if err != nil {
+			return
+		}
+		volumes[minor] = procDrbdVolume{resource, volume}
+	}
+
+	for _, entry := range entries {
+		link := filepath.Join(byRes, entry.Name())
+		if entry.Type()&os.ModeSymlink != 0 {
+			addVolume(link, entry.Name(), 0)
+			continue
+		}
+		volumeEntries, err := os.ReadDir(link)
+		if err != nil {
+			continue
+		}
+		for _, volumeEntry := range volumeEntries {
+			volume, err := strconv.Atoi(volumeEntry.Name())
+			if err != nil {
+				continue
+			}
+			addVolume(filepath.Join(link, volumeEntry.Name()), entry.Name(), volume)
+		}
+	}
+
+	return volumes
+}
+
+// procDrbdSizes reads the size of the DRBD devices from sysfs, in KiB
+func procDrbdSizes(sysfsPath string, minors []int) map[int]int {
+	sizes := make(map[int]int)
+	for _, minor := range minors {
+		raw, err := os.ReadFile(filepath.Join(sysfsPath, "block", "drbd"+strconv.Itoa(minor), "size"))
+		if err != nil {
+			continue
+		}
+		// the size is always in 512 bytes sectors
+		sectors, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+		if err != nil {
+			continue
+		}
+		sizes[minor] = sectors / 2
+	}
+	return sizes
+}
+
+// readProcDrbd reads the status of the DRBD 8.4 devices from /proc/drbd, in the same structure as `drbdsetup status`
+func readProcDrbd(procfsPath string, sysfsPath string, devPath string) ([]drbdStatus, error) {
+	raw, err := os.ReadFile(filepath.Join(procfsPath, "drbd"))
+	if err != nil {
+		return nil, err
+	}
+	minors, err := procDrbdMinors(raw)
+	if err != nil {
+		return nil, err
+	}
+	return parseProcDrbd(raw, procDrbdVolumes(devPath), procDrbdSizes(sysfsPath, minors))
+}
+
+// procDrbdMinors returns the minor numbers of all the configured devices in /proc/drbd
+func procDrbdMinors(procDrbdRaw []byte) ([]int, error) {
+	var minors []int
+	scanner := bufio.NewScanner(bytes.NewReader(procDrbdRaw))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") || fields[1] == "cs:Unconfigured" {
+			continue
+		}
+		if minor, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":")); err == nil {
+			minors = append(minors, minor)
+		}
+	}
+	return minors, scanner.Err()
+}
+
+// parseProcDrbd parses the content of /proc/drbd in DRBD 8.4, which looks like this:
+/*
+	version: 8.4.11-1 (api:1/proto:86-101)
+	GIT-hash: 66145a308421e9c124ec391a7848ac20203bb03c build by mockbuild@, 2018-11-03 01:26:55
+	 0: cs:Connected ro:Primary/Secondary ds:UpToDate/UpToDate C r-----
+	    ns:1048508 nr:0 dw:4 dr:1050665 al:1 bm:0 lo:0 pe:0 ua:0 ap:0 ep:1 wo:f oos:0
+	 1: cs:SyncSource ro:Primary/Secondary ds:UpToDate/Inconsistent C r-----
+	    ns:2138112 nr:0 dw:0 dr:2140240 al:8 bm:0 lo:0 pe:2 ua:0 ap:0 ep:1 wo:f oos:8346696
+		[==>.................] sync'ed: 20.4% (8148/10236)M
+		finish: 0:02:01 speed: 68,752 (68,752) K/sec
+	 2: cs:Unconfigured
+*/
+// the devices are mapped onto resources via the given volumes, falling back to a resource named after the device, e.g. `drbd0`;
+// the sizes, in KiB, are needed to compute the in sync percentage like DRBD 9 does
+func parseProcDrbd(procDrbdRaw []byte, volumes map[int]procDrbdVolume, sizes map[int]int) ([]drbdStatus, error) {
+	var statuses []drbdStatus
+	resourceIndexes := make(map[string]int)
+
+	// the device and the peer device of the minor being parsed
+	var device *drbdDevice
+	var peerDevice *drbdPeerDevice
+
+	scanner := bufio.NewScanner(bytes.NewReader(procDrbdRaw))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if minor, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":")); err == nil && strings.HasSuffix(fields[0], ":") {
+			device, peerDevice = nil, nil
+			states := procDrbdProperties(fields[1:])
+			if states["cs"] == "" || states["cs"] == "Unconfigured" {
+				continue
+			}
+
+			volume, ok := volumes[minor]
+			if !ok {
+				volume = procDrbdVolume{"drbd" + strconv.Itoa(minor), 0}
+			}
+			index, ok := resourceIndexes[volume.Resource]
+			if !ok {
+				// there are no node ids in DRBD 8.4, which supports a single peer only
+				statuses = append(statuses, drbdStatus{Name: volume.Resource, Connections: make([]drbdConnection, 1)})
+				index = len(statuses) - 1
+				resourceIndexes[volume.Resource] = index
+			}
+			resource := &statuses[index]
+
+			role, peerRole, _ := strings.Cut(states["ro"], "/")
+			disk, peerDisk, _ := strings.Cut(states["ds"], "/")
+			resource.Role = role
+			resource.Devices = append(resource.Devices, drbdDevice{Volume: volume.Volume, Minor: minor, Size: sizes[minor], DiskState: disk})
+			device = &resource.Devices[len(resource.Devices)-1]
+
+			// DRBD 8.4 reports the replication state in place of the connection state, while connected
+			connection := &resource.Connections[0]
+			connection.PeerRole = peerRole
+			connection.ConnectionState = states["cs"]
+			replicationState := "Off"
+			if states["cs"] == "Connected" {
+				replicationState = "Established"
+			} else if procDrbdReplicationStates[states["cs"]] {
+				connection.ConnectionState = "Connected"
+				replicationState = states["cs"]
+			}
+			connection.PeerDevices = append(connection.PeerDevices, drbdPeerDevice{Volume: volume.Volume, ReplicationState: replicationState, PeerDiskState: peerDisk, ResyncSuspended: "no"})
+			peerDevice = &connection.PeerDevices[len(connection.PeerDevices)-1]
+
+			// the last field holds the flags, e.g. `r---c-`: the 1st is whether the I/O is suspended,
+			// the 2nd to 4th are the reasons why the resync is suspended, the 5th is the reason why the connection is congested,
+			// and the 6th is whether the activity log is suspended
+			if flags := fields[len(fields)-1]; len(flags) == 6 && !strings.Contains(flags, ":") {
+				if flags[0] == 's' {
+					resource.Suspended = true
+				}
+				if flags[5] == 's' {
+					device.AlSuspended = true
+				}
+				if flags[1:4] != "---" {
+					peerDevice.ResyncSuspended = "yes"
+				}
+				if flags[4] != '-' {
+					connection.Congested = true
+				}
+			}
+			continue
+		}
+
+		if device == nil {
+			continue
+		}
+
+		if matches := procDrbdSyncRe.FindStringSubmatch(line); matches != nil {
+			total, _ := strconv.Atoi(matches[2])
+			if matches[3] == "M" {
+				total *= 1024
+			}
+			peerDevice.HasSyncDetails = true
+			peerDevice.RsTotal = total
+			continue
+		}
+		if matches := procDrbdSpeedRe.FindStringSubmatch(line); matches != nil {
+			// the recent speed is in KiB per second, i.e. 2 sectors per second
+			speed, _ := strconv.Atoi(strings.ReplaceAll(matches[1], ",", ""))
+			peerDevice.RsDt0Ms = 1000
+			peerDevice.RsDb0Sectors = 2 * speed
+			continue
+		}
+
+		statistics := procDrbdProperties(fields)
+		if _, ok := statistics["ns"]; !ok {
+			continue
+		}
+		for key, target := range map[string]*int{
+			"dw": &device.Written, "dr": &device.Read, "al": &device.AlWrites, "bm": &device.BmWrites,
+			"ap": &device.UpPending, "lo": &device.LoPending,
+			"ns": &peerDevice.Sent, "nr": &peerDevice.Received, "pe": &peerDevice.Pending, "ua": &peerDevice.Unacked,
+			"oos": &peerDevice.OutOfSync,
+		} {
+			value, err := strconv.Atoi(statistics[key])
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not parse the %s statistic of minor %d", key, device.Minor)
+			}
+			*target = value
+		}
+
+		if size, ok := sizes[device.Minor]; ok && size > 0 {
+			peerDevice.PercentInSync = 100 * (1 - float64(peerDevice.OutOfSync)/float64(size))
+		} else if peerDevice.OutOfSync == 0 {
+			peerDevice.PercentInSync = 100
+		}
+	}
+
+	return statuses, scanner.Err()
+}
+
+// procDrbdProperties collects the `key:value` fields of a /proc/drbd line
+func procDrbdProperties(fields []string) map[string]string {
+	properties := make(map[string]string)
+	for _, field := range fields {
+		if key, value, ok := strings.Cut(field, ":"); ok {
+			properties[key] = value
+		}
+	}
+	return properties
+}
@@ -0,0 +1,100 @@
+package drbd
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
+)
+
+func TestDrbdModuleMajorVersion(t *testing.T) {
+	version, err := drbdModuleMajorVersion("../../test/drbd84/sysfs")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 8, version)
+}
+
+func TestDrbdModuleMajorVersionWithoutModule(t *testing.T) {
+	_, err := drbdModuleMajorVersion("../../test/sysfs")
+
+	assert.Error(t, err)
+}
+
+func TestProcDrbdVolumes(t *testing.T) {
+	volumes := procDrbdVolumes("../../test/drbd84/dev")
+
+	assert.Equal(t, map[int]procDrbdVolume{
+		0: {"r0", 0},
+		1: {"r1", 0},
+		2: {"r1", 1},
+	}, volumes)
+}
+
+func TestProcDrbdParsing(t *testing.T) {
+	procDrbdRaw := []byte(`version: 8.4.11-1 (api:1/proto:86-101)
+GIT-hash: 66145a308421e9c124ec391a7848ac20203bb03c build by mockbuild@, 2018-11-03 01:26:55
+ 0: cs:SyncTarget ro:Secondary/Primary ds:Inconsistent/UpToDate C r---c-
+    ns:0 nr:2138112 dw:2138112 dr:0 al:0 bm:130 lo:1 pe:2 ua:1 ap:0 ep:1 wo:f oos:8343552
+	[==>.................] sync'ed: 20.4% (8148/10236)M
+	finish: 0:02:01 speed: 68,752 (68,752) K/sec
+ 1: cs:WFConnection ro:Secondary/Unknown ds:UpToDate/DUnknown C r-----
+    ns:0 nr:0 dw:0 dr:0 al:0 bm:0 lo:0 pe:0 ua:0 ap:0 ep:1 wo:f oos:1024
+ 2: cs:Unconfigured
+`)
+
+	statuses, err := parseProcDrbd(procDrbdRaw, map[int]procDrbdVolume{0: {"r0", 0}}, map[int]int{0: 10481664})
+
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+
+	assert.Equal(t, "r0", statuses[0].Name)
+	assert.Equal(t, "Secondary", statuses[0].Role)
+	assert.Equal(t, drbdDevice{Volume: 0, Minor: 0, Size: 10481664, Written: 2138112, BmWrites: 130, LoPending: 1, DiskState: "Inconsistent"}, statuses[0].Devices[0])
+	assert.Equal(t, "Primary", statuses[0].Connections[0].PeerRole)
+	assert.Equal(t, "Connected", statuses[0].Connections[0].ConnectionState)
+	assert.True(t, statuses[0].Connections[0].Congested)
+	peerDevice := statuses[0].Connections[0].PeerDevices[0]
+	assert.Equal(t, "SyncTarget", peerDevice.ReplicationState)
+	assert.Equal(t, "UpToDate", peerDevice.PeerDiskState)
+	assert.Equal(t, "no", peerDevice.ResyncSuspended)
+	assert.Equal(t, 2138112, peerDevice.Received)
+	assert.Equal(t, 2, peerDevice.Pending)
+	assert.Equal(t, 1, peerDevice.Unacked)
+	assert.Equal(t, 8343552, peerDevice.OutOfSync)
+	assert.InDelta(t, 20.4, peerDevice.PercentInSync, 0.1)
+	assert.True(t, peerDevice.HasSyncDetails)
+	assert.Equal(t, 10236*1024, peerDevice.RsTotal)
+	assert.Equal(t, 1000, peerDevice.RsDt0Ms)
+	assert.Equal(t, 137504, peerDevice.RsDb0Sectors)
+
+	// the resource is named after the device when it can't be resolved, and the in sync percentage is unknown without its size
+	assert.Equal(t, "drbd1", statuses[1].Name)
+	assert.Equal(t, "WFConnection", statuses[1].Connections[0].ConnectionState)
+	assert.Equal(t, "Off", statuses[1].Connections[0].PeerDevices[0].ReplicationState)
+	assert.Equal(t, 0.0, statuses[1].Connections[0].PeerDevices[0].PercentInSync)
+	assert.False(t, statuses[1].Connections[0].PeerDevices[0].HasSyncDetails)
+}
+
+func TestProcDrbdParsingFailsOnInvalidStatistics(t *testing.T) {
+	procDrbdRaw := []byte(` 0: cs:Connected ro:Primary/Secondary ds:UpToDate/UpToDate C r-----
+    ns:foo nr:0 dw:0 dr:0 al:0 bm:0 lo:0 pe:0 ua:0 ap:0 ep:1 wo:f oos:0
+`)
+
+	_, err := parseProcDrbd(procDrbdRaw, nil, nil)
+
+	assert.EqualError(t, err, "could not parse the ns statistic of minor 0: strconv.Atoi: parsing \"foo\": invalid syntax")
+}
+
+func TestDRBD84Collector(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
+	collector.sysfsPath = "../../test/drbd84/sysfs"
+	collector.procfsPath = "../../test/drbd84/procfs"
+	collector.devPath = "../../test/drbd84/dev"
+	// the configuration is the same regardless of the DRBD version
+	collector.drbdadmPath = ""
+
+	assertcustom.Metrics(t, collector, "drbd84.metrics")
+}
@@ -0,0 +1,136 @@
+package drbd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+// the default directory drbd-reactor reads its configuration snippets from
+const defaultReactorSnippetsPath = "/etc/drbd-reactor.d"
+
+// reactorConfig is the relevant part of a drbd-reactor configuration file, i.e. the promoter plugins
+type reactorConfig struct {
+	Snippets string `toml:"snippets"`
+	Promoter []struct {
+		Resources map[string]promoterResource `toml:"resources"`
+	} `toml:"promoter"`
+}
+
+// promoterResource is a DRBD resource managed by the drbd-reactor promoter plugin,
+// which promotes it on one of the nodes and starts the units depending on it there
+type promoterResource struct {
+	Start []string `toml:"start"`
+	// either `systemd`, the default, or `shell`
+	Runner string `toml:"runner"`
+}
+
+// readReactorConfig reads the resources managed by the promoter from the drbd-reactor configuration file and its snippets;
+// like drbd-reactor, the snippets are the `.toml` files in the directory set in the main file
+func readReactorConfig(configPath string) (map[string]promoterResource, error) {
+	resources := make(map[string]promoterResource)
+
+	config, err := parseReactorConfigFile(configPath, resources)
+	if err != nil {
+		return nil, err
+	}
+
+	snippetsPath := config.Snippets
+	if snippetsPath == "" {
+		snippetsPath = defaultReactorSnippetsPath
+	}
+	snippets, err := filepath.Glob(filepath.Join(snippetsPath, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	for _, snippet := range snippets {
+		_, err := parseReactorConfigFile(snippet, resources)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resources, nil
+}
+
+func parseReactorConfigFile(path string, resources map[string]promoterResource) (reactorConfig, error) {
+	var config reactorConfig
+	configRaw, err := os.ReadFile(path)
+	if err != nil {
+		return config, errors.Wrapf(err, "could not read drbd-reactor config file %s", path)
+	}
+	err = toml.Unmarshal(configRaw, &config)
+	if err != nil {
+		return config, errors.Wrapf(err, "could not parse drbd-reactor config file %s", path)
+	}
+
+	for _, promoter := range config.Promoter {
+		for name, resource := range promoter.Resources {
+			if resource.Runner == "" {
+				resource.Runner = "systemd"
+			}
+			resources[name] = resource
+		}
+	}
+	return config, nil
+}
+
+// promoterTarget is the systemd target the promoter generates for a resource, which requires all its start units
+func promoterTarget(resource string) string {
+	return "drbd-services@" + resource + ".target"
+}
+
+// systemdResources returns the names of the resources run by the systemd runner, which are the only ones with a target, in order
+func systemdResources(resources map[string]promoterResource) []string {
+	var names []string
+	for name, resource := range resources {
+		if resource.Runner == "systemd" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// promoterTargetStatesArgs returns the arguments of systemctl to query the state of the targets of the given resources
+func promoterTargetStatesArgs(resources []string) []string {
+	args := []string{"is-active"}
+	for _, resource := range resources {
+		args = append(args, promoterTarget(resource))
+	}
+	return args
+}
+
+// promoterTargetStates queries systemd for the state of the targets of the given resources, e.g. `active` or `failed`
+func promoterTargetStates(ctx context.Context, executor collector.Executor, systemctlPath string, resources []string) (map[string]string, error) {
+	states := make(map[string]string)
+	if len(resources) == 0 {
+		return states, nil
+	}
+	sort.Strings(resources)
+
+	// systemctl exits with an error when any of the units is not active, but it still prints all their states
+	stateRaw, err := collector.RunCommand(ctx, executor, systemctlPath, promoterTargetStatesArgs(resources)...)
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, err
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(stateRaw)), "\n")
+	if len(lines) != len(resources) {
+		return nil, errors.Errorf("unexpected systemctl is-active output: %s", stateRaw)
+	}
+	for i, resource := range resources {
+		states[resource] = strings.TrimSpace(lines[i])
+	}
+	return states, nil
+}
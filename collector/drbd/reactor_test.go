@@ -0,0 +1,88 @@
+package drbd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+func TestReadReactorConfig(t *testing.T) {
+	resources, err := readReactorConfig("../../test/drbd-reactor.toml")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]promoterResource{
+		"1-single-0": {Start: []string{"srv-nfs.mount", "nfs-server.service"}, Runner: "systemd"},
+		"1-single-1": {Start: []string{"/usr/local/bin/start-app.sh"}, Runner: "shell"},
+	}, resources)
+}
+
+func TestReadReactorConfigFailsOnInvalidToml(t *testing.T) {
+	_, err := readReactorConfig("../../test/fake_sbdconfig")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not parse drbd-reactor config file ../../test/fake_sbdconfig")
+}
+
+func TestPromoterTargetStates(t *testing.T) {
+	states, err := promoterTargetStates(context.Background(), collector.DirectExecutor{}, "../../test/fake_systemctl.sh", []string{"r1", "r0"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"r0": "inactive", "r1": "inactive"}, states)
+}
+
+func TestDRBDCollectorWithReactor(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", "../../test/drbd-reactor.toml", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
+
+	expect := `
+	# HELP ha_cluster_drbd_promoter_promoted Whether DRBD resources managed by the drbd-reactor promoter are promoted on this node
+	# TYPE ha_cluster_drbd_promoter_promoted gauge
+	ha_cluster_drbd_promoter_promoted{resource="1-single-0"} 0
+	ha_cluster_drbd_promoter_promoted{resource="1-single-1"} 0
+	# HELP ha_cluster_drbd_promoter_resources The DRBD resources managed by the drbd-reactor promoter
+	# TYPE ha_cluster_drbd_promoter_resources gauge
+	ha_cluster_drbd_promoter_resources{resource="1-single-0",runner="systemd"} 1
+	ha_cluster_drbd_promoter_resources{resource="1-single-1",runner="shell"} 1
+	# HELP ha_cluster_drbd_promoter_start_units The units started by the drbd-reactor promoter where DRBD resources are promoted
+	# TYPE ha_cluster_drbd_promoter_start_units gauge
+	ha_cluster_drbd_promoter_start_units{resource="1-single-0",unit="nfs-server.service"} 1
+	ha_cluster_drbd_promoter_start_units{resource="1-single-0",unit="srv-nfs.mount"} 1
+	ha_cluster_drbd_promoter_start_units{resource="1-single-1",unit="/usr/local/bin/start-app.sh"} 1
+	# HELP ha_cluster_drbd_promoter_target_state The state of the systemd targets of DRBD resources managed by the drbd-reactor promoter
+	# TYPE ha_cluster_drbd_promoter_target_state gauge
+	ha_cluster_drbd_promoter_target_state{resource="1-single-0",state="inactive"} 1
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect),
+		"ha_cluster_drbd_promoter_promoted",
+		"ha_cluster_drbd_promoter_resources",
+		"ha_cluster_drbd_promoter_start_units",
+		"ha_cluster_drbd_promoter_target_state",
+	)
+
+	assert.NoError(t, err)
+}
+
+func TestDRBDCollectorWithoutReactor(t *testing.T) {
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", "fake", "../../test/nonexistent", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
+
+	count := testutil.CollectAndCount(collector, "ha_cluster_drbd_promoter_resources")
+
+	assert.Equal(t, 0, count)
+}
+
+func TestDrbdCommandsWithReactor(t *testing.T) {
+	commands, err := Commands("/sbin/drbdsetup", "/sbin/drbdadm", "../../test/drbd-reactor.toml", "/usr/bin/systemctl", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"/sbin/drbdsetup", "status", "--json", "--statistics"},
+		{"/sbin/drbdadm", "dump-xml"},
+		{"/usr/bin/systemctl", "is-active", "drbd-services@1-single-0.target"},
+	}, commands)
+}
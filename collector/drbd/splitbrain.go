@@ -0,0 +1,120 @@
+package drbd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the prefix of the marker files of the split brains detected by DRBD, which are named after the resource and the volume
+const splitBrainMarkerPrefix = "drbd-split-brain-detected-"
+
+var (
+	splitBrainMarkerRe = regexp.MustCompile(splitBrainMarkerPrefix + `(?P<resource>[\w-]+)-(?P<volume>[\w-]+)`)
+	// the resource and volume names that can be told apart in the name of a marker
+	splitBrainResourceRe = regexp.MustCompile(`^[\w-]+$`)
+	splitBrainVolumeRe   = regexp.MustCompile(`^\w+$`)
+)
+
+// SplitBrainMarker is a split brain detected by DRBD, which is reported until its marker file is removed
+type SplitBrainMarker struct {
+	Path       string
+	Resource   string
+	Volume     string
+	DetectedAt time.Time
+}
+
+// ListSplitBrainMarkers returns the split brain markers in a directory, skipping the files whose name doesn't match;
+// the detection time is the modification time of the marker
+func ListSplitBrainMarkers(dir string) ([]SplitBrainMarker, error) {
+	files, err := filepath.Glob(filepath.Join(dir, splitBrainMarkerPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var markers []SplitBrainMarker
+	for _, f := range files {
+		// matches[0] will be the whole file name, matches[1] the resource, matches[2] the volume
+		matches := splitBrainMarkerRe.FindStringSubmatch(filepath.Base(f))
+		if matches == nil {
+			continue
+		}
+		info, err := os.Stat(f)
+		// the marker may have been cleared in the meantime
+		if err != nil {
+			continue
+		}
+		markers = append(markers, SplitBrainMarker{f, matches[1], matches[2], info.ModTime()})
+	}
+	return markers, nil
+}
+
+// WriteSplitBrainMarker records a split brain of a resource volume, to be called by the DRBD `split-brain` handler;
+// the marker is written atomically, so that it's never seen half written, and an existing one is replaced
+func WriteSplitBrainMarker(dir string, resource string, volume string, peerNodeId string, now time.Time) error {
+	if !splitBrainResourceRe.MatchString(resource) || !splitBrainVolumeRe.MatchString(volume) {
+		return errors.Errorf("invalid resource '%s' or volume '%s'", resource, volume)
+	}
+	marker := splitBrainMarkerPrefix + resource + "-" + volume
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "could not create %s", dir)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+marker+"-")
+	if err != nil {
+		return errors.Wrap(err, "could not create the split brain marker")
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = fmt.Fprintf(tmp, "resource=%s\nvolume=%s\npeer_node_id=%s\ndetected_at=%s\n", resource, volume, peerNodeId, now.Format(time.RFC3339))
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not write the split brain marker")
+	}
+	err = os.Chtimes(tmp.Name(), now, now)
+	if err != nil {
+		return errors.Wrap(err, "could not write the split brain marker")
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return errors.Wrap(err, "could not write the split brain marker")
+	}
+
+	err = os.Rename(tmp.Name(), filepath.Join(dir, marker))
+	if err != nil {
+		return errors.Wrap(err, "could not write the split brain marker")
+	}
+	return nil
+}
+
+// ClearSplitBrainMarkers removes the split brain markers of a resource, or of all of them when the resource is empty;
+// the volume narrows it down further, when not empty; it returns the markers that were removed
+func ClearSplitBrainMarkers(dir string, resource string, volume string) ([]SplitBrainMarker, error) {
+	markers, err := ListSplitBrainMarkers(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var cleared []SplitBrainMarker
+	for _, marker := range markers {
+		if (resource != "" && marker.Resource != resource) || (volume != "" && marker.Volume != volume) {
+			continue
+		}
+		err := os.Remove(marker.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return cleared, errors.Wrapf(err, "could not clear the split brain marker of resource %s, volume %s", marker.Resource, marker.Volume)
+		}
+		cleared = append(cleared, marker)
+	}
+	return cleared, nil
+}
@@ -0,0 +1,101 @@
+package drbd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+func TestListSplitBrainMarkers(t *testing.T) {
+	markers, err := ListSplitBrainMarkers("../../test/drbd-splitbrain")
+
+	assert.NoError(t, err)
+	assert.Len(t, markers, 2)
+	assert.Equal(t, "resource01", markers[0].Resource)
+	assert.Equal(t, "vol01", markers[0].Volume)
+	assert.Equal(t, "resource02", markers[1].Resource)
+	assert.Equal(t, "vol02", markers[1].Volume)
+}
+
+func TestWriteSplitBrainMarker(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "splitbrain")
+	detectedAt := time.Date(2020, 1, 15, 10, 13, 44, 0, time.UTC)
+
+	err := WriteSplitBrainMarker(dir, "1-single-0", "0", "1", detectedAt)
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(dir, "drbd-split-brain-detected-1-single-0-0"))
+	assert.NoError(t, err)
+	assert.Equal(t, "resource=1-single-0\nvolume=0\npeer_node_id=1\ndetected_at=2020-01-15T10:13:44Z\n", string(content))
+
+	markers, err := ListSplitBrainMarkers(dir)
+	assert.NoError(t, err)
+	assert.Len(t, markers, 1)
+	assert.Equal(t, "1-single-0", markers[0].Resource)
+	assert.Equal(t, "0", markers[0].Volume)
+	assert.True(t, detectedAt.Equal(markers[0].DetectedAt))
+
+	// no temporary file is left behind
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteSplitBrainMarkerRejectsInvalidNames(t *testing.T) {
+	dir := t.TempDir()
+
+	err := WriteSplitBrainMarker(dir, "../r0", "0", "1", time.Now())
+	assert.EqualError(t, err, "invalid resource '../r0' or volume '0'")
+
+	err = WriteSplitBrainMarker(dir, "r0", "", "1", time.Now())
+	assert.EqualError(t, err, "invalid resource 'r0' or volume ''")
+}
+
+func TestClearSplitBrainMarkers(t *testing.T) {
+	dir := t.TempDir()
+	for _, marker := range [][]string{{"r0", "0"}, {"r0", "1"}, {"r1", "0"}} {
+		assert.NoError(t, WriteSplitBrainMarker(dir, marker[0], marker[1], "1", time.Now()))
+	}
+
+	cleared, err := ClearSplitBrainMarkers(dir, "r0", "1")
+	assert.NoError(t, err)
+	assert.Len(t, cleared, 1)
+	assert.Equal(t, "1", cleared[0].Volume)
+
+	cleared, err = ClearSplitBrainMarkers(dir, "r0", "")
+	assert.NoError(t, err)
+	assert.Len(t, cleared, 1)
+	assert.Equal(t, "0", cleared[0].Volume)
+
+	cleared, err = ClearSplitBrainMarkers(dir, "", "")
+	assert.NoError(t, err)
+	assert.Len(t, cleared, 1)
+	assert.Equal(t, "r1", cleared[0].Resource)
+
+	markers, err := ListSplitBrainMarkers(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, markers)
+}
+
+func TestDRBDSplitbrainTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, WriteSplitBrainMarker(dir, "resource01", "0", "1", time.Unix(1579083224, 0)))
+	collector, _ := NewCollector("../../test/fake_drbdsetup.sh", "../../test/fake_drbdadm.sh", dir, "", "../../test/fake_systemctl.sh", false, false, collector.DirectExecutor{}, false, log.NewNopLogger())
+
+	expect := `
+	# HELP ha_cluster_drbd_split_brain_detected_timestamp_seconds When a split brain was detected, as a Unix timestamp; 1 line per resource, per volume.
+	# TYPE ha_cluster_drbd_split_brain_detected_timestamp_seconds gauge
+	ha_cluster_drbd_split_brain_detected_timestamp_seconds{resource="resource01",volume="0"} 1.579083224e+09
+	`
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expect), "ha_cluster_drbd_split_brain_detected_timestamp_seconds")
+
+	assert.NoError(t, err)
+}
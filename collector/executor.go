@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Executor runs the external commands the collectors read their data sources with, with the privileges they require
+type Executor interface {
+	// Command returns the command running the given executable, which is stopped when the context expires
+	Command(ctx context.Context, path string, args ...string) *exec.Cmd
+}
+
+// DirectExecutor runs the commands directly, with the privileges of the exporter itself; this is the default,
+// which requires running as root, unless the commands only require the privileges of the haclient group
+type DirectExecutor struct{}
+
+func (DirectExecutor) Command(ctx context.Context, path string, args ...string) *exec.Cmd {
+	return newCommand(ctx, syscall.SIGKILL, path, args...)
+}
+
+// SudoExecutor runs the commands as root via `sudo -n`, i.e. without ever prompting for a password,
+// so they must be allowed by the sudoers policy; see Sudoers
+type SudoExecutor struct {
+	SudoPath string
+}
+
+func (e SudoExecutor) Command(ctx context.Context, path string, args ...string) *exec.Cmd {
+	// an unprivileged exporter can't kill the commands run as root, so it terminates sudo, which relays the signal to them
+	return newCommand(ctx, syscall.SIGTERM, e.SudoPath, append([]string{"-n", "--", path}, args...)...)
+}
+
+// Sudoers returns a sudoers policy allowing the given user to run exactly the given commands, and nothing else, as root
+func Sudoers(user string, commands [][]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# allows %s to run the commands of ha_cluster_exporter as root, via sudo -n\n", user)
+	fmt.Fprintf(&b, "Defaults:%s !requiretty\n", user)
+	allowed := make(map[string]bool)
+	for _, command := range commands {
+		args := make([]string, len(command))
+		for i, arg := range command {
+			args[i] = sudoersEscape(arg)
+		}
+		// without arguments, sudo would allow any, unless an empty argument list is given explicitly
+		if len(command) == 1 {
+			args = append(args, `""`)
+		}
+		// some commands are run by more than one collector, e.g. cibadmin
+		spec := strings.Join(args, " ")
+		if allowed[spec] {
+			continue
+		}
+		allowed[spec] = true
+		fmt.Fprintf(&b, "%s ALL=(root) NOPASSWD: %s\n", user, spec)
+	}
+	return b.String()
+}
+
+// escapes the characters that are special in the command specifications of sudoers, including the wildcards
+func sudoersEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`, `:`, `\:`, `=`, `\=`, ` `, `\ `, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSudoExecutor(t *testing.T) {
+	cmd := SudoExecutor{"/usr/bin/sudo"}.Command(context.Background(), "/usr/sbin/crm_mon", "-X", "--inactive")
+
+	assert.Equal(t, "/usr/bin/sudo", cmd.Path)
+	assert.Equal(t, []string{"/usr/bin/sudo", "-n", "--", "/usr/sbin/crm_mon", "-X", "--inactive"}, cmd.Args)
+}
+
+func TestSudoers(t *testing.T) {
+	sudoers := Sudoers("prometheus", [][]string{
+		{"/usr/sbin/crm_mon", "-X", "--inactive"},
+		{"/usr/sbin/corosync-cmapctl"},
+		{"/usr/sbin/sbd", "-d", "/dev/disk/by-id/scsi-1:0,1", "dump"},
+		{"/usr/sbin/crm_mon", "-X", "--inactive"},
+	})
+
+	expected := `# allows prometheus to run the commands of ha_cluster_exporter as root, via sudo -n
+Defaults:prometheus !requiretty
+prometheus ALL=(root) NOPASSWD: /usr/sbin/crm_mon -X --inactive
+prometheus ALL=(root) NOPASSWD: /usr/sbin/corosync-cmapctl ""
+prometheus ALL=(root) NOPASSWD: /usr/sbin/sbd -d /dev/disk/by-id/scsi-1\:0\,1 dump
+`
+	assert.Equal(t, expected, sudoers)
+}
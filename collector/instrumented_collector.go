@@ -1,6 +1,11 @@
 package collector
 
 import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
 	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -14,20 +19,49 @@ import (
 type InstrumentableCollector interface {
 	prometheus.Collector
 	SubsystemCollector
-	CollectWithError(ch chan<- prometheus.Metric) error
+	CollectWithError(ctx context.Context, ch chan<- prometheus.Metric) error
 }
 
 type InstrumentedCollector struct {
 	collector          InstrumentableCollector
+	timeout            time.Duration
 	Clock              clock.Clock
 	scrapeDurationDesc *prometheus.Desc
 	scrapeSuccessDesc  *prometheus.Desc
+	scrapeTimeoutDesc  *prometheus.Desc
+	scrapeAgeDesc      *prometheus.Desc
 	logger             log.Logger
+	state              *scrapeState
+}
+
+// scrapeState keeps track of the scrapes of a collector, so that they can be shared
+type scrapeState struct {
+	sync.Mutex
+	polling  bool
+	inflight *scrapeCall
+	latest   *scrapeSnapshot
 }
 
-func NewInstrumentedCollector(collector InstrumentableCollector, logger log.Logger) *InstrumentedCollector {
+// scrapeCall is a scrape in progress, which any concurrent one waits for, instead of running the collector again
+type scrapeCall struct {
+	done     chan struct{}
+	snapshot *scrapeSnapshot
+}
+
+// scrapeSnapshot holds the metrics collected by a scrape, along with its outcome
+type scrapeSnapshot struct {
+	metrics  []prometheus.Metric
+	duration time.Duration
+	success  float64
+	timedOut float64
+	time     time.Time
+}
+
+// the timeout is the deadline given to each collection cycle, after which any external command still running is killed
+func NewInstrumentedCollector(collector InstrumentableCollector, timeout time.Duration, logger log.Logger) *InstrumentedCollector {
 	return &InstrumentedCollector{
 		collector,
+		timeout,
 		&clock.SystemClock{},
 		prometheus.NewDesc(
 			prometheus.BuildFQName(NAMESPACE, "scrape", "duration_seconds"),
@@ -45,28 +79,139 @@ func NewInstrumentedCollector(collector InstrumentableCollector, logger log.Logg
 				"collector": collector.GetSubsystem(),
 			},
 		),
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, "scrape", "timeout"),
+			"Whether a collector timed out.",
+			nil,
+			prometheus.Labels{
+				"collector": collector.GetSubsystem(),
+			},
+		),
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, "scrape", "age_seconds"),
+			"Time elapsed since the metrics of a collector were polled.",
+			nil,
+			prometheus.Labels{
+				"collector": collector.GetSubsystem(),
+			},
+		),
 		logger,
+		&scrapeState{},
 	}
 }
 
+// Poll scrapes the collector in background at the given interval, until the context is done;
+// from then on, the metrics of the latest poll are served, rather than scraping the collector again each time
+func (ic *InstrumentedCollector) Poll(ctx context.Context, interval time.Duration) {
+	ic.state.Lock()
+	ic.state.polling = true
+	ic.state.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			ic.scrape()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
 func (ic *InstrumentedCollector) Collect(ch chan<- prometheus.Metric) {
-	var success float64
+	ic.state.Lock()
+	polling, snapshot := ic.state.polling, ic.state.latest
+	ic.state.Unlock()
+
+	// until the first poll completes, it's waited for
+	if !polling || snapshot == nil {
+		snapshot = ic.scrape()
+	}
+
+	for _, metric := range snapshot.metrics {
+		ch <- metric
+	}
+	ch <- prometheus.MustNewConstMetric(ic.scrapeDurationDesc, prometheus.GaugeValue, snapshot.duration.Seconds())
+	ch <- prometheus.MustNewConstMetric(ic.scrapeSuccessDesc, prometheus.GaugeValue, snapshot.success)
+	ch <- prometheus.MustNewConstMetric(ic.scrapeTimeoutDesc, prometheus.GaugeValue, snapshot.timedOut)
+	if polling {
+		ch <- prometheus.MustNewConstMetric(ic.scrapeAgeDesc, prometheus.GaugeValue, ic.Clock.Since(snapshot.time).Seconds())
+	}
+}
+
+// scrape runs the collector, unless a scrape is already in progress, in which case its snapshot is shared
+func (ic *InstrumentedCollector) scrape() *scrapeSnapshot {
+	ic.state.Lock()
+	if call := ic.state.inflight; call != nil {
+		ic.state.Unlock()
+		<-call.done
+		return call.snapshot
+	}
+	call := &scrapeCall{done: make(chan struct{})}
+	ic.state.inflight = call
+	ic.state.Unlock()
+
+	call.snapshot = ic.collect()
+
+	ic.state.Lock()
+	ic.state.inflight = nil
+	ic.state.latest = call.snapshot
+	ic.state.Unlock()
+	close(call.done)
+
+	return call.snapshot
+}
+
+// collect runs a collection cycle, within the timeout, and buffers its metrics
+func (ic *InstrumentedCollector) collect() *scrapeSnapshot {
+	snapshot := &scrapeSnapshot{}
+	metrics := make(chan prometheus.Metric)
+	buffered := make(chan struct{})
+	go func() {
+		for metric := range metrics {
+			snapshot.metrics = append(snapshot.metrics, metric)
+		}
+		close(buffered)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ic.timeout)
+	defer cancel()
 	begin := ic.Clock.Now()
-	err := ic.collector.CollectWithError(ch)
-	duration := ic.Clock.Since(begin)
+	err := ic.collector.CollectWithError(ctx, metrics)
+	snapshot.duration = ic.Clock.Since(begin)
+	close(metrics)
+	<-buffered
+
 	if err == nil {
-		success = 1
+		snapshot.success = 1
 	} else {
 		level.Warn(ic.logger).Log("msg", ic.collector.GetSubsystem()+" collector scrape failed", "err", err)
 	}
-	ch <- prometheus.MustNewConstMetric(ic.scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds())
-	ch <- prometheus.MustNewConstMetric(ic.scrapeSuccessDesc, prometheus.GaugeValue, success)
+	if ctx.Err() == context.DeadlineExceeded {
+		snapshot.timedOut = 1
+	}
+	snapshot.time = ic.Clock.Now()
+
+	return snapshot
 }
 
 func (ic *InstrumentedCollector) Describe(ch chan<- *prometheus.Desc) {
 	ic.collector.Describe(ch)
 	ch <- ic.scrapeDurationDesc
 	ch <- ic.scrapeSuccessDesc
+	ch <- ic.scrapeTimeoutDesc
+	ch <- ic.scrapeAgeDesc
+}
+
+// Close releases the resources of the collector, e.g. its background watchers, if it has any
+func (ic *InstrumentedCollector) Close() error {
+	if closer, ok := ic.collector.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
 func (ic *InstrumentedCollector) GetSubsystem() string {
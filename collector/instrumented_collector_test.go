@@ -1,12 +1,15 @@
 package collector
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 
@@ -21,9 +24,9 @@ func TestInstrumentedCollector(t *testing.T) {
 	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
 	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
 	mockCollector.EXPECT().Describe(gomock.Any())
-	mockCollector.EXPECT().CollectWithError(gomock.Any())
+	mockCollector.EXPECT().CollectWithError(gomock.Any(), gomock.Any())
 
-	SUT := NewInstrumentedCollector(mockCollector, log.NewNopLogger())
+	SUT := NewInstrumentedCollector(mockCollector, time.Second, log.NewNopLogger())
 	SUT.Clock = &clock.StoppedClock{}
 
 	metrics := `# HELP ha_cluster_scrape_duration_seconds Duration of a collector scrape.
@@ -32,6 +35,9 @@ ha_cluster_scrape_duration_seconds{collector="mock_collector"} 1.234
 # HELP ha_cluster_scrape_success Whether a collector succeeded.
 # TYPE ha_cluster_scrape_success gauge
 ha_cluster_scrape_success{collector="mock_collector"} 1
+# HELP ha_cluster_scrape_timeout Whether a collector timed out.
+# TYPE ha_cluster_scrape_timeout gauge
+ha_cluster_scrape_timeout{collector="mock_collector"} 0
 `
 
 	err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics))
@@ -45,10 +51,10 @@ func TestInstrumentedCollectorScrapeFailure(t *testing.T) {
 	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
 	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
 	mockCollector.EXPECT().Describe(gomock.Any())
-	collectWithError := mockCollector.EXPECT().CollectWithError(gomock.Any())
+	collectWithError := mockCollector.EXPECT().CollectWithError(gomock.Any(), gomock.Any())
 	collectWithError.Return(errors.New("test error"))
 
-	SUT := NewInstrumentedCollector(mockCollector, log.NewNopLogger())
+	SUT := NewInstrumentedCollector(mockCollector, time.Second, log.NewNopLogger())
 
 	metrics := `# HELP ha_cluster_scrape_success Whether a collector succeeded.
 # TYPE ha_cluster_scrape_success gauge
@@ -60,3 +66,141 @@ ha_cluster_scrape_success{collector="mock_collector"} 0
 
 	assert.NotNil(t, collectWithError)
 }
+
+func TestInstrumentedCollectorScrapeTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+	mockCollector.EXPECT().Describe(gomock.Any())
+	mockCollector.EXPECT().CollectWithError(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ch chan<- prometheus.Metric) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	)
+
+	SUT := NewInstrumentedCollector(mockCollector, 10*time.Millisecond, log.NewNopLogger())
+
+	metrics := `# HELP ha_cluster_scrape_success Whether a collector succeeded.
+# TYPE ha_cluster_scrape_success gauge
+ha_cluster_scrape_success{collector="mock_collector"} 0
+# HELP ha_cluster_scrape_timeout Whether a collector timed out.
+# TYPE ha_cluster_scrape_timeout gauge
+ha_cluster_scrape_timeout{collector="mock_collector"} 1
+`
+
+	err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics), "ha_cluster_scrape_success", "ha_cluster_scrape_timeout")
+	assert.NoError(t, err)
+}
+
+func TestInstrumentedCollectorPolling(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+	mockCollector.EXPECT().Describe(gomock.Any()).AnyTimes()
+	mockCollector.EXPECT().CollectWithError(gomock.Any(), gomock.Any()).Times(1)
+
+	SUT := NewInstrumentedCollector(mockCollector, time.Second, log.NewNopLogger())
+	SUT.Clock = &clock.StoppedClock{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	SUT.Poll(ctx, time.Hour)
+	assert.Eventually(t, func() bool {
+		SUT.state.Lock()
+		defer SUT.state.Unlock()
+		return SUT.state.latest != nil
+	}, time.Second, time.Millisecond)
+
+	metrics := `# HELP ha_cluster_scrape_age_seconds Time elapsed since the metrics of a collector were polled.
+# TYPE ha_cluster_scrape_age_seconds gauge
+ha_cluster_scrape_age_seconds{collector="mock_collector"} 1.234
+# HELP ha_cluster_scrape_success Whether a collector succeeded.
+# TYPE ha_cluster_scrape_success gauge
+ha_cluster_scrape_success{collector="mock_collector"} 1
+`
+
+	// the scrapes are served the snapshot of the first poll
+	for i := 0; i < 2; i++ {
+		err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics), "ha_cluster_scrape_age_seconds", "ha_cluster_scrape_success")
+		assert.NoError(t, err)
+	}
+}
+
+func TestInstrumentedCollectorConcurrentScrapes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+	mockCollector.EXPECT().CollectWithError(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ch chan<- prometheus.Metric) error {
+			<-release
+			return nil
+		},
+	).Times(1)
+
+	SUT := NewInstrumentedCollector(mockCollector, time.Second, log.NewNopLogger())
+
+	// the scrapes started while another one is in progress wait for its outcome
+	scrapes := make(chan int)
+	for i := 0; i < 3; i++ {
+		go func() {
+			ch := make(chan prometheus.Metric, 10)
+			SUT.Collect(ch)
+			scrapes <- len(ch)
+		}()
+	}
+	assert.Eventually(t, func() bool {
+		SUT.state.Lock()
+		defer SUT.state.Unlock()
+		return SUT.state.inflight != nil
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 3, <-scrapes)
+	}
+}
+
+type closableCollector struct {
+	InstrumentableCollector
+	closed bool
+}
+
+func (c *closableCollector) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestInstrumentedCollectorClose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+	closable := &closableCollector{InstrumentableCollector: mockCollector}
+
+	SUT := NewInstrumentedCollector(closable, time.Second, log.NewNopLogger())
+
+	assert.NoError(t, SUT.Close())
+	assert.True(t, closable.closed)
+}
+
+func TestInstrumentedCollectorCloseNotClosable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCollector := mock_collector.NewMockInstrumentableCollector(ctrl)
+	mockCollector.EXPECT().GetSubsystem().Return("mock_collector").AnyTimes()
+
+	SUT := NewInstrumentedCollector(mockCollector, time.Second, log.NewNopLogger())
+
+	assert.NoError(t, SUT.Close())
+}
@@ -16,6 +16,7 @@ type Root struct {
 		Nodes []struct {
 			Id                 string      `xml:"id,attr"`
 			Uname              string      `xml:"uname,attr"`
+			Type               string      `xml:"type,attr"`
 			InstanceAttributes []Attribute `xml:"instance_attributes>nvpair"`
 		} `xml:"nodes>node"`
 		Resources struct {
@@ -0,0 +1,66 @@
+package cib
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	durationRegex    = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-z]*)$`)
+	isoDurationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+)
+
+// the units of the Pacemaker durations; a bare number is in seconds
+var durationUnits = map[string]time.Duration{
+	"":     time.Second,
+	"s":    time.Second,
+	"sec":  time.Second,
+	"ms":   time.Millisecond,
+	"msec": time.Millisecond,
+	"us":   time.Microsecond,
+	"usec": time.Microsecond,
+	"m":    time.Minute,
+	"min":  time.Minute,
+	"h":    time.Hour,
+	"hr":   time.Hour,
+}
+
+// ParseDuration parses a duration like Pacemaker does for timeouts and intervals, e.g. `60`, `90s`, `2min` or `PT1M30S`;
+// note that, unlike in Go, `m` stands for minutes
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if match := durationRegex.FindStringSubmatch(value); match != nil {
+		unit, ok := durationUnits[match[2]]
+		if !ok {
+			return 0, errors.Errorf("unknown unit in duration '%s'", value)
+		}
+		number, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "could not parse duration '%s'", value)
+		}
+		return time.Duration(number * float64(unit)), nil
+	}
+
+	// ISO 8601 durations are also accepted, as long as they don't use months or years, whose length varies
+	if match := isoDurationRegex.FindStringSubmatch(strings.ToUpper(value)); match != nil && value != "p" && !strings.HasSuffix(value, "t") {
+		var duration time.Duration
+		for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+			if match[i+1] == "" {
+				continue
+			}
+			number, err := strconv.ParseFloat(match[i+1], 64)
+			if err != nil {
+				return 0, errors.Wrapf(err, "could not parse duration '%s'", value)
+			}
+			duration += time.Duration(number * float64(unit))
+		}
+		return duration, nil
+	}
+
+	return 0, errors.Errorf("could not parse duration '%s'", value)
+}
@@ -0,0 +1,40 @@
+package cib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"60", 60 * time.Second},
+		{"90s", 90 * time.Second},
+		{"1.5sec", 1500 * time.Millisecond},
+		{"500ms", 500 * time.Millisecond},
+		{"250us", 250 * time.Microsecond},
+		{"2m", 2 * time.Minute},
+		{" 2min ", 2 * time.Minute},
+		{"1h", time.Hour},
+		{"1HR", time.Hour},
+		{"PT1M30S", 90 * time.Second},
+		{"P1DT1H", 25 * time.Hour},
+	}
+
+	for _, testCase := range testCases {
+		duration, err := ParseDuration(testCase.value)
+		assert.NoError(t, err, testCase.value)
+		assert.Equal(t, testCase.expected, duration, testCase.value)
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	for _, value := range []string{"", "-1", "10 days", "1y", "P", "PT", "P1M"} {
+		_, err := ParseDuration(value)
+		assert.Error(t, err, value)
+	}
+}
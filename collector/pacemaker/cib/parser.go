@@ -1,23 +1,26 @@
 package cib
 
 import (
+	"context"
 	"encoding/xml"
-	"os/exec"
 
 	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
 type Parser interface {
-	Parse() (Root, error)
+	Parse(ctx context.Context) (Root, error)
 }
 
 type cibAdminParser struct {
 	cibAdminPath string
+	executor     collector.Executor
 }
 
-func (p *cibAdminParser) Parse() (Root, error) {
+func (p *cibAdminParser) Parse(ctx context.Context) (Root, error) {
 	var CIB Root
-	cibXML, err := exec.Command(p.cibAdminPath, "--query", "--local").Output()
+	cibXML, err := collector.RunCommand(ctx, p.executor, p.cibAdminPath, "--query", "--local")
 	if err != nil {
 		return CIB, errors.Wrap(err, "error while executing cibadmin")
 	}
@@ -30,6 +33,6 @@ func (p *cibAdminParser) Parse() (Root, error) {
 	return CIB, nil
 }
 
-func NewCibAdminParser(cibAdminPath string) *cibAdminParser {
-	return &cibAdminParser{cibAdminPath}
+func NewCibAdminParser(cibAdminPath string, executor collector.Executor) *cibAdminParser {
+	return &cibAdminParser{cibAdminPath, executor}
 }
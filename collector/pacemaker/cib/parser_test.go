@@ -1,19 +1,22 @@
 package cib
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
 func TestConstructor(t *testing.T) {
-	p := NewCibAdminParser("foo")
+	p := NewCibAdminParser("foo", collector.DirectExecutor{})
 	assert.Equal(t, "foo", p.cibAdminPath)
 }
 
 func TestParse(t *testing.T) {
-	p := NewCibAdminParser("../../../test/fake_cibadmin.sh")
-	data, err := p.Parse()
+	p := NewCibAdminParser("../../../test/fake_cibadmin.sh", collector.DirectExecutor{})
+	data, err := p.Parse(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(data.Configuration.Nodes))
 	assert.Equal(t, "cib-bootstrap-options-cluster-name", data.Configuration.CrmConfig.ClusterProperties[3].Id)
@@ -1,22 +1,25 @@
 package crmmon
 
 import (
+	"context"
 	"encoding/xml"
-	"os/exec"
 
 	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
 type Parser interface {
-	Parse() (Root, error)
+	Parse(ctx context.Context) (Root, error)
 }
 
 type crmMonParser struct {
 	crmMonPath string
+	executor   collector.Executor
 }
 
-func (c *crmMonParser) Parse() (crmMon Root, err error) {
-	crmMonXML, err := exec.Command(c.crmMonPath, "-X", "--inactive").Output()
+func (c *crmMonParser) Parse(ctx context.Context) (crmMon Root, err error) {
+	crmMonXML, err := collector.RunCommand(ctx, c.executor, c.crmMonPath, "-X", "--inactive")
 	if err != nil {
 		return crmMon, errors.Wrap(err, "error while executing crm_mon")
 	}
@@ -29,6 +32,6 @@ func (c *crmMonParser) Parse() (crmMon Root, err error) {
 	return crmMon, nil
 }
 
-func NewCrmMonParser(crmMonPath string) *crmMonParser {
-	return &crmMonParser{crmMonPath}
+func NewCrmMonParser(crmMonPath string, executor collector.Executor) *crmMonParser {
+	return &crmMonParser{crmMonPath, executor}
 }
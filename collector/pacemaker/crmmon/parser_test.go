@@ -1,19 +1,22 @@
 package crmmon
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
 func TestConstructor(t *testing.T) {
-	p := NewCrmMonParser("foo")
+	p := NewCrmMonParser("foo", collector.DirectExecutor{})
 	assert.Equal(t, "foo", p.crmMonPath)
 }
 
 func TestParse(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
-	data, err := p.Parse()
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", collector.DirectExecutor{})
+	data, err := p.Parse(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, "2.0.0", data.Version)
 	assert.Equal(t, 8, data.Summary.Resources.Number)
@@ -54,8 +57,8 @@ func TestParse(t *testing.T) {
 }
 
 func TestParseClones(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
-	data, err := p.Parse()
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", collector.DirectExecutor{})
+	data, err := p.Parse(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 3, len(data.Clones))
 	assert.Equal(t, "msl_SAPHana_PRD_HDB00", data.Clones[0].Id)
@@ -70,8 +73,8 @@ func TestParseClones(t *testing.T) {
 }
 
 func TestParseGroups(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
-	data, err := p.Parse()
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", collector.DirectExecutor{})
+	data, err := p.Parse(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(data.Groups))
 
@@ -89,8 +92,8 @@ func TestParseGroups(t *testing.T) {
 }
 
 func TestParseNodeAttributes(t *testing.T) {
-	p := NewCrmMonParser("../../../test/fake_crm_mon.sh")
-	data, err := p.Parse()
+	p := NewCrmMonParser("../../../test/fake_crm_mon.sh", collector.DirectExecutor{})
+	data, err := p.Parse(context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, data.NodeAttributes.Nodes, 2)
 	assert.Equal(t, "node01", data.NodeAttributes.Nodes[0].Name)
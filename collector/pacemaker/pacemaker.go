@@ -1,6 +1,7 @@
 package pacemaker
 
 import (
+	"context"
 	"math"
 	"strconv"
 	"strings"
@@ -18,7 +19,7 @@ import (
 
 const subsystem = "pacemaker"
 
-func NewCollector(crmMonPath string, cibAdminPath string, timestamps bool, logger log.Logger) (*pacemakerCollector, error) {
+func NewCollector(crmMonPath string, cibAdminPath string, executor collector.Executor, timestamps bool, logger log.Logger) (*pacemakerCollector, error) {
 	err := collector.CheckExecutables(crmMonPath, cibAdminPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
@@ -26,8 +27,8 @@ func NewCollector(crmMonPath string, cibAdminPath string, timestamps bool, logge
 
 	c := &pacemakerCollector{
 		collector.NewDefaultCollector(subsystem, timestamps, logger),
-		crmmon.NewCrmMonParser(crmMonPath),
-		cib.NewCibAdminParser(cibAdminPath),
+		crmmon.NewCrmMonParser(crmMonPath, executor),
+		cib.NewCibAdminParser(cibAdminPath, executor),
 	}
 	c.SetDescriptor("nodes", "The status of each node in the cluster; 1 means the node is in that status, 0 otherwise", []string{"node", "type", "status"})
 	c.SetDescriptor("node_attributes", "Metadata attributes of each node; value is always 1", []string{"node", "name", "value"})
@@ -48,17 +49,23 @@ type pacemakerCollector struct {
 	cibParser    cib.Parser
 }
 
-func (c *pacemakerCollector) CollectWithError(ch chan<- prometheus.Metric) error {
+func (c *pacemakerCollector) CollectWithError(ctx context.Context, ch chan<- prometheus.Metric) error {
 	level.Debug(c.Logger).Log("msg", "Collecting pacemaker metrics...")
 
-	crmMon, err := c.crmMonParser.Parse()
-	if err != nil {
+	var crmMon crmmon.Root
+	var CIB cib.Root
+	workers := collector.NewWorkers(ctx, collector.DefaultParallelism)
+	workers.Go(func(ctx context.Context) (err error) {
+		crmMon, err = c.crmMonParser.Parse(ctx)
 		return errors.Wrap(err, "crm_mon parser error")
-	}
-
-	CIB, err := c.cibParser.Parse()
-	if err != nil {
+	})
+	workers.Go(func(ctx context.Context) (err error) {
+		CIB, err = c.cibParser.Parse(ctx)
 		return errors.Wrap(err, "cibadmin parser error")
+	})
+	err := workers.Wait()
+	if err != nil {
+		return err
 	}
 
 	c.recordStonithStatus(crmMon, ch)
@@ -81,7 +88,7 @@ func (c *pacemakerCollector) CollectWithError(ch chan<- prometheus.Metric) error
 func (c *pacemakerCollector) Collect(ch chan<- prometheus.Metric) {
 	level.Debug(c.Logger).Log("msg", "Collecting pacemaker metrics...")
 
-	err := c.CollectWithError(ch)
+	err := c.CollectWithError(context.Background(), ch)
 	if err != nil {
 		level.Warn(c.Logger).Log("msg", c.GetSubsystem()+" collector scrape failed", "err", err)
 	}
@@ -255,3 +262,11 @@ func (c *pacemakerCollector) recordNodeAttributes(crmMon crmmon.Root, ch chan<-
 		}
 	}
 }
+
+// Commands returns the commands the collector runs, with their arguments, e.g. to allow them in a sudoers policy
+func Commands(crmMonPath string, cibAdminPath string) [][]string {
+	return [][]string{
+		{crmMonPath, "-X", "--inactive"},
+		{cibAdminPath, "--query", "--local"},
+	}
+}
@@ -0,0 +1,96 @@
+package sbd
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+)
+
+// the Pacemaker default for the stonith-timeout cluster property, in seconds
+const defaultStonithTimeout = 60
+
+// timeoutCheck is the outcome of one of the rules that relate the SBD timeouts to each other and to the Pacemaker ones;
+// the device is empty for the rules that don't depend on the SBD devices
+type timeoutCheck struct {
+	Rule    string
+	Device  string
+	Value   float64
+	Minimum float64
+}
+
+func (c timeoutCheck) passed() bool {
+	return c.Value >= c.Minimum
+}
+
+// checkTimeouts verifies the timeouts relationships documented by SBD and Pacemaker:
+// - `msgwait` must be at least twice the `watchdog` timeout of each device, so that a node has self-fenced when the message is considered delivered
+// - `stonith-timeout` must be at least `msgwait` plus 20%, so that Pacemaker doesn't give up on a fencing that is still in progress
+// - `stonith-watchdog-timeout` must be at least twice `SBD_WATCHDOG_TIMEOUT` in diskless mode, for the same reason as `msgwait`
+// The rules involving the Pacemaker timeouts are skipped when the CIB is not given, i.e. when it couldn't be read.
+func checkTimeouts(headers map[string]sbdHeader, sbdConfig sbdConfig, CIB *cib.Root) ([]timeoutCheck, error) {
+	var checks []timeoutCheck
+
+	stonithTimeout := float64(defaultStonithTimeout)
+	// an unset stonith-watchdog-timeout is the same as 0, i.e. watchdog fencing is disabled
+	stonithWatchdogTimeout := float64(0)
+	var clusterProperties []cib.Attribute
+	if CIB != nil {
+		clusterProperties = CIB.Configuration.CrmConfig.ClusterProperties
+	}
+	for _, property := range clusterProperties {
+		switch property.Name {
+		case "stonith-timeout":
+			duration, err := cib.ParseDuration(property.Value)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not parse stonith-timeout")
+			}
+			stonithTimeout = duration.Seconds()
+		case "stonith-watchdog-timeout":
+			// negative values are special, meaning that Pacemaker uses twice the SBD_WATCHDOG_TIMEOUT
+			if len(property.Value) > 0 && property.Value[0] == '-' {
+				stonithWatchdogTimeout = -1
+				continue
+			}
+			duration, err := cib.ParseDuration(property.Value)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not parse stonith-watchdog-timeout")
+			}
+			stonithWatchdogTimeout = duration.Seconds()
+		}
+	}
+
+	devices := make([]string, 0, len(headers))
+	for device := range headers {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	for _, device := range devices {
+		msgwait, hasMsgwait := headers[device].Timeouts["msgwait"]
+		watchdog, hasWatchdog := headers[device].Timeouts["watchdog"]
+		if !hasMsgwait {
+			continue
+		}
+		if hasWatchdog {
+			checks = append(checks, timeoutCheck{"msgwait", device, msgwait, 2 * watchdog})
+		}
+		if CIB != nil {
+			checks = append(checks, timeoutCheck{"stonith_timeout", device, stonithTimeout, 1.2 * msgwait})
+		}
+	}
+
+	if len(sbdConfig.devices()) == 0 && CIB != nil {
+		watchdogTimeout, err := sbdConfig.number("SBD_WATCHDOG_TIMEOUT")
+		if err != nil {
+			return nil, err
+		}
+		if stonithWatchdogTimeout < 0 {
+			stonithWatchdogTimeout = 2 * watchdogTimeout
+		}
+		checks = append(checks, timeoutCheck{"stonith_watchdog_timeout", "", stonithWatchdogTimeout, 2 * watchdogTimeout})
+	}
+
+	return checks, nil
+}
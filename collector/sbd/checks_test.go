@@ -0,0 +1,89 @@
+package sbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
+)
+
+func cibWithProperties(properties ...cib.Attribute) *cib.Root {
+	CIB := &cib.Root{}
+	CIB.Configuration.CrmConfig.ClusterProperties = properties
+	return CIB
+}
+
+func TestCheckTimeouts(t *testing.T) {
+	headers := map[string]sbdHeader{
+		"/dev/vdd": {Timeouts: map[string]float64{"watchdog": 15, "msgwait": 30}},
+		"/dev/vdc": {Timeouts: map[string]float64{"watchdog": 9, "msgwait": 10}},
+		// an unreadable header has no timeouts
+		"/dev/vde": {Timeouts: map[string]float64{}},
+	}
+	sbdConfig := sbdConfig{"SBD_DEVICE": "/dev/vdc;/dev/vdd;/dev/vde"}
+	CIB := cibWithProperties(cib.Attribute{Name: "stonith-timeout", Value: "30s"})
+
+	checks, err := checkTimeouts(headers, sbdConfig, CIB)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []timeoutCheck{
+		{"msgwait", "/dev/vdc", 10, 18},
+		{"stonith_timeout", "/dev/vdc", 30, 12},
+		{"msgwait", "/dev/vdd", 30, 30},
+		{"stonith_timeout", "/dev/vdd", 30, 36},
+	}, checks)
+	assert.False(t, checks[0].passed())
+	assert.True(t, checks[1].passed())
+	assert.True(t, checks[2].passed())
+	assert.False(t, checks[3].passed())
+}
+
+func TestCheckTimeoutsDefaults(t *testing.T) {
+	headers := map[string]sbdHeader{
+		"/dev/vdc": {Timeouts: map[string]float64{"watchdog": 5, "msgwait": 10}},
+	}
+
+	checks, err := checkTimeouts(headers, sbdConfig{"SBD_DEVICE": "/dev/vdc"}, cibWithProperties())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []timeoutCheck{
+		{"msgwait", "/dev/vdc", 10, 10},
+		{"stonith_timeout", "/dev/vdc", 60, 12},
+	}, checks)
+}
+
+func TestCheckTimeoutsWithoutCib(t *testing.T) {
+	headers := map[string]sbdHeader{
+		"/dev/vdc": {Timeouts: map[string]float64{"watchdog": 5, "msgwait": 10}},
+	}
+
+	// only the rules that don't depend on the Pacemaker timeouts are checked
+	checks, err := checkTimeouts(headers, sbdConfig{"SBD_DEVICE": "/dev/vdc"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []timeoutCheck{{"msgwait", "/dev/vdc", 10, 10}}, checks)
+
+	checks, err = checkTimeouts(nil, sbdConfig{}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, checks)
+}
+
+func TestCheckTimeoutsDiskless(t *testing.T) {
+	sbdConfig := sbdConfig{"SBD_WATCHDOG_TIMEOUT": "15"}
+
+	checks, err := checkTimeouts(nil, sbdConfig, cibWithProperties(cib.Attribute{Name: "stonith-watchdog-timeout", Value: "20"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []timeoutCheck{{"stonith_watchdog_timeout", "", 20, 30}}, checks)
+
+	// a negative value makes Pacemaker compute it from the SBD watchdog timeout
+	checks, err = checkTimeouts(nil, sbdConfig, cibWithProperties(cib.Attribute{Name: "stonith-watchdog-timeout", Value: "-1"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []timeoutCheck{{"stonith_watchdog_timeout", "", 30, 30}}, checks)
+}
+
+func TestCheckTimeoutsError(t *testing.T) {
+	_, err := checkTimeouts(nil, sbdConfig{}, cibWithProperties(cib.Attribute{Name: "stonith-timeout", Value: "forever"}))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not parse stonith-timeout")
+}
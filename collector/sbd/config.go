@@ -0,0 +1,160 @@
+package sbd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// sbdConfig holds the options of the sbd daemon, keyed by name, e.g. SBD_DEVICE
+type sbdConfig map[string]string
+
+// the defaults of the sbd daemon for the options that are not in the configuration
+var sbdConfigDefaults = map[string]string{
+	"SBD_DEVICE":                "",
+	"SBD_PACEMAKER":             "yes",
+	"SBD_STARTMODE":             "always",
+	"SBD_DELAY_START":           "no",
+	"SBD_WATCHDOG_DEV":          defaultWatchdogDevice,
+	"SBD_WATCHDOG_TIMEOUT":      "5",
+	"SBD_TIMEOUT_ACTION":        "flush,reboot",
+	"SBD_MOVE_TO_ROOT_CGROUP":   "auto",
+	"SBD_SYNC_RESOURCE_STARTUP": "no",
+	"SBD_OPTS":                  "",
+}
+
+var sbdConfigKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// get returns the value of an option, or its default if it is unset or empty, like sbd does
+func (c sbdConfig) get(name string) string {
+	if value := c[name]; value != "" {
+		return value
+	}
+	return sbdConfigDefaults[name]
+}
+
+// devices returns the list of the SBD devices, which are separated by semicolons
+func (c sbdConfig) devices() []string {
+	var devices []string
+	for _, device := range strings.Split(c.get("SBD_DEVICE"), ";") {
+		device = strings.TrimSpace(device)
+		if device != "" {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// number returns the value of a boolean or numeric option as a number; booleans are 1 or 0
+func (c sbdConfig) number(name string) (float64, error) {
+	value := c.get(name)
+	switch strings.ToLower(value) {
+	case "yes", "y", "true", "on":
+		return 1, nil
+	case "no", "n", "false", "off":
+		return 0, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse %s", name)
+	}
+	return number, nil
+}
+
+// parseSbdConfig parses the contents of /etc/sysconfig/sbd, which is both sourced by shell scripts and read by systemd
+// as an environment file, so its syntax is the common subset of the two:
+// one `KEY=value` assignment per line, optionally prefixed with `export`, where the value can be single or double quoted,
+// or mix quoted and unquoted parts, and backslashes escape the next character everywhere but in single quotes;
+// lines starting with `#` are comments, as well as anything after an unquoted ` #`, and the last assignment of a key wins.
+// Unlike a shell, whitespace in unquoted values is preserved, like systemd does, so that `/dev/vdc; /dev/vdd` is kept together.
+// Malformed lines are skipped with a warning, like systemd does, so that a single typo doesn't hide the other options.
+func parseSbdConfig(sbdConfigRaw []byte, logger log.Logger) sbdConfig {
+	config := make(sbdConfig)
+
+	// a backslash at the end of a line continues it on the next one
+	content := strings.ReplaceAll(string(sbdConfigRaw), "\\\n", "")
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, err := parseSbdConfigLine(line)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping malformed sbd config line", "line", i+1, "err", err)
+			continue
+		}
+		config[key] = value
+	}
+
+	return config
+}
+
+// parseSbdConfigLine parses a `KEY=value` assignment
+func parseSbdConfigLine(line string) (string, string, error) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", errors.New("not an assignment")
+	}
+	key = strings.TrimSpace(key)
+	if !sbdConfigKeyRegex.MatchString(key) {
+		return "", "", errors.Errorf("invalid key '%s'", key)
+	}
+
+	value, err := unquoteSbdConfigValue(strings.TrimSpace(value))
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// unquoteSbdConfigValue removes the quotes, escapes and trailing comments from a value
+func unquoteSbdConfigValue(raw string) (string, error) {
+	var value strings.Builder
+	// the length of the value up to its last quoted or non blank character, to trim the whitespace before a comment
+	end := 0
+	var quote rune
+	escaped := false
+
+	for i, r := range raw {
+		switch {
+		case escaped:
+			// in double quotes, the backslash only escapes the characters that have a special meaning in there
+			if quote == '"' && !strings.ContainsRune("\"\\$`", r) {
+				value.WriteRune('\\')
+			}
+			value.WriteRune(r)
+			end = value.Len()
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			value.WriteRune(r)
+			end = value.Len()
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || raw[i-1] == ' ' || raw[i-1] == '\t'):
+			return value.String()[:end], nil
+		default:
+			value.WriteRune(r)
+			if r != ' ' && r != '\t' {
+				end = value.Len()
+			}
+		}
+	}
+
+	if quote != 0 {
+		return "", errors.Errorf("unbalanced %c quote", quote)
+	}
+	if escaped {
+		return "", errors.New("dangling backslash")
+	}
+	return value.String()[:end], nil
+}
@@ -0,0 +1,92 @@
+package sbd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSbdConfig(t *testing.T) {
+	sbdConfigRaw := `
+# a comment
+	 SBD_PACEMAKER=yes
+export SBD_STARTMODE=clean
+SBD_DELAY_START = "30"
+SBD_WATCHDOG_DEV='/dev/watchdog0' # the hardware watchdog
+SBD_TIMEOUT_ACTION=flush,"crash"dump
+SBD_OPTS="-v \"-n hana01\" \d"
+SBD_DEVICE=/dev/vdc;\
+/dev/vdd
+SBD_MOVE_TO_ROOT_CGROUP=auto#no
+SBD_PACEMAKER=no
+SBD_SYNC_RESOURCE_STARTUP=`
+
+	config := parseSbdConfig([]byte(sbdConfigRaw), log.NewNopLogger())
+
+	assert.Equal(t, sbdConfig{
+		"SBD_PACEMAKER":             "no",
+		"SBD_STARTMODE":             "clean",
+		"SBD_DELAY_START":           "30",
+		"SBD_WATCHDOG_DEV":          "/dev/watchdog0",
+		"SBD_TIMEOUT_ACTION":        "flush,crashdump",
+		"SBD_OPTS":                  `-v "-n hana01" \d`,
+		"SBD_DEVICE":                "/dev/vdc;/dev/vdd",
+		"SBD_MOVE_TO_ROOT_CGROUP":   "auto#no",
+		"SBD_SYNC_RESOURCE_STARTUP": "",
+	}, config)
+}
+
+func TestParseSbdConfigUnbalancedQuotes(t *testing.T) {
+	var logs bytes.Buffer
+	config := parseSbdConfig([]byte("SBD_PACEMAKER=yes\nSBD_DEVICE=\"/dev/vdc;/dev/vdd\n"), log.NewLogfmtLogger(&logs))
+
+	assert.Equal(t, sbdConfig{"SBD_PACEMAKER": "yes"}, config)
+	assert.Contains(t, logs.String(), `line=2 err="unbalanced \" quote"`)
+}
+
+func TestParseSbdConfigInvalidLines(t *testing.T) {
+	var logs bytes.Buffer
+	config := parseSbdConfig([]byte("SBD_PACEMAKER\nSBD-PACEMAKER=yes\nSBD_DEVICE=/dev/vdc\nSBD_OPTS=-v\\"), log.NewLogfmtLogger(&logs))
+
+	// the valid lines are still parsed
+	assert.Equal(t, sbdConfig{"SBD_DEVICE": "/dev/vdc"}, config)
+	assert.Contains(t, logs.String(), `line=1 err="not an assignment"`)
+	assert.Contains(t, logs.String(), `line=2 err="invalid key 'SBD-PACEMAKER'"`)
+	assert.Contains(t, logs.String(), `line=4 err="dangling backslash"`)
+}
+
+func TestSbdConfigDefaults(t *testing.T) {
+	config := sbdConfig{"SBD_STARTMODE": "clean", "SBD_PACEMAKER": ""}
+
+	assert.Equal(t, "clean", config.get("SBD_STARTMODE"))
+	assert.Equal(t, "yes", config.get("SBD_PACEMAKER"))
+	assert.Equal(t, "/dev/watchdog", config.get("SBD_WATCHDOG_DEV"))
+	assert.Nil(t, config.devices())
+}
+
+func TestSbdConfigNumber(t *testing.T) {
+	config := sbdConfig{
+		"SBD_PACEMAKER":        "yes",
+		"SBD_DELAY_START":      "no",
+		"SBD_WATCHDOG_TIMEOUT": "15",
+		"SBD_STARTMODE":        "always",
+	}
+
+	value, err := config.number("SBD_PACEMAKER")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), value)
+
+	value, err = config.number("SBD_DELAY_START")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), value)
+
+	value, err = config.number("SBD_WATCHDOG_TIMEOUT")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(15), value)
+
+	_, err = config.number("SBD_STARTMODE")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not parse SBD_STARTMODE")
+}
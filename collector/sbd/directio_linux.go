@@ -0,0 +1,5 @@
+package sbd
+
+import "syscall"
+
+const oDirect = syscall.O_DIRECT
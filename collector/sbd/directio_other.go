@@ -0,0 +1,6 @@
+//go:build !linux
+
+package sbd
+
+// direct I/O is not available on this platform, so the reads may hit the page cache
+const oDirect = 0
@@ -0,0 +1,69 @@
+package sbd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sbdHeader is the metadata header of an SBD device
+type sbdHeader struct {
+	Uuid       string
+	Version    string
+	Slots      uint64
+	SectorSize uint64
+	// the timeouts in seconds, keyed by type, e.g. `watchdog` or `msgwait`
+	Timeouts map[string]float64
+}
+
+// parses the header from this kind of output from `sbd -d <device> dump`
+/*
+	==Dumping header on disk /dev/vdc
+	Header version     : 2.1
+	UUID               : 1ed3171d-066d-47ca-8f76-aec25d9efed4
+	Number of slots    : 255
+	Sector size        : 512
+	Timeout (watchdog) : 9
+	Timeout (allocate) : 2
+	Timeout (loop)     : 1
+	Timeout (msgwait)  : 10
+	==Header on disk /dev/vdc is dumped
+*/
+func parseSbdDump(sbdDump []byte) (header sbdHeader, err error) {
+	header.Timeouts = make(map[string]float64)
+
+	lineRe := regexp.MustCompile(`(?m)^(?P<key>[^:=\n]+?)\s*:\s*(?P<value>.*?)\s*$`)
+	timeoutRe := regexp.MustCompile(`^Timeout \((?P<type>\w+)\)$`)
+	for _, match := range lineRe.FindAllStringSubmatch(string(sbdDump), -1) {
+		key, value := match[1], match[2]
+
+		if timeoutMatch := timeoutRe.FindStringSubmatch(key); timeoutMatch != nil {
+			header.Timeouts[timeoutMatch[1]], err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return header, errors.Wrapf(err, "could not parse %s timeout", timeoutMatch[1])
+			}
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "uuid":
+			header.Uuid = value
+		case "header version":
+			header.Version = value
+		case "number of slots":
+			header.Slots, err = strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return header, errors.Wrap(err, "could not parse number of slots")
+			}
+		case "sector size":
+			header.SectorSize, err = strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return header, errors.Wrap(err, "could not parse sector size")
+			}
+		}
+	}
+
+	return header, nil
+}
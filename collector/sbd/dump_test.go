@@ -0,0 +1,56 @@
+package sbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSbdDump(t *testing.T) {
+	sbdDump := []byte(`==Dumping header on disk /dev/vdc
+Header version     : 2.1
+UUID               : 1ed3171d-066d-47ca-8f76-aec25d9efed4
+Number of slots    : 255
+Sector size        : 512
+Timeout (watchdog) : 9
+Timeout (allocate) : 2
+Timeout (loop)     : 1
+Timeout (msgwait)  : 10
+==Header on disk /dev/vdc is dumped`)
+
+	header, err := parseSbdDump(sbdDump)
+
+	assert.NoError(t, err)
+	assert.Equal(t, sbdHeader{
+		Uuid:       "1ed3171d-066d-47ca-8f76-aec25d9efed4",
+		Version:    "2.1",
+		Slots:      255,
+		SectorSize: 512,
+		Timeouts: map[string]float64{
+			"watchdog": 9,
+			"allocate": 2,
+			"loop":     1,
+			"msgwait":  10,
+		},
+	}, header)
+}
+
+func TestParseSbdDumpEmpty(t *testing.T) {
+	header, err := parseSbdDump(nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, header.Uuid)
+	assert.Empty(t, header.Timeouts)
+}
+
+func TestParseSbdDumpTimeoutError(t *testing.T) {
+	_, err := parseSbdDump([]byte(`Timeout (msgwait)  : ten`))
+
+	assert.EqualError(t, err, `could not parse msgwait timeout: strconv.ParseFloat: parsing "ten": invalid syntax`)
+}
+
+func TestParseSbdDumpSlotsError(t *testing.T) {
+	_, err := parseSbdDump([]byte(`Number of slots    : -1`))
+
+	assert.EqualError(t, err, `could not parse number of slots: strconv.ParseUint: parsing "-1": invalid syntax`)
+}
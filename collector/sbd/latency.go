@@ -0,0 +1,159 @@
+package sbd
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
+)
+
+const (
+	// how long a read of an SBD device can take before it is considered failed; it is not aborted, though
+	deviceReadTimeout = 5 * time.Second
+	// direct I/O requires the buffers to be aligned to the logical block size of the device, which is at most a page
+	directIOAlignment = 4096
+)
+
+// the upper bounds of the read latency buckets, in seconds; SBD devices are read every `loop` timeout,
+// and the node self-fences when they can't be read within the `watchdog` timeout, which is usually a few seconds
+var deviceReadBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// latencyHistogram accumulates the latency observations, with cumulative bucket counts
+type latencyHistogram struct {
+	Count   uint64
+	Sum     float64
+	Buckets map[float64]uint64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	h := &latencyHistogram{Buckets: make(map[float64]uint64, len(deviceReadBuckets))}
+	for _, bound := range deviceReadBuckets {
+		h.Buckets[bound] = 0
+	}
+	return h
+}
+
+func (h *latencyHistogram) observe(seconds float64) {
+	h.Count++
+	h.Sum += seconds
+	for bound := range h.Buckets {
+		if seconds <= bound {
+			h.Buckets[bound]++
+		}
+	}
+}
+
+// deviceProber measures the latency of reading the SBD devices, bypassing the page cache like SBD itself does;
+// reads that hang are left behind, since they can't be interrupted, but only one at a time per device
+type deviceProber struct {
+	sync.Mutex
+	read       func(device string, size int64) error
+	clock      clock.Clock
+	timeout    time.Duration
+	histograms map[string]*latencyHistogram
+	failures   map[string]uint64
+	pending    map[string]bool
+}
+
+func newDeviceProber() *deviceProber {
+	return &deviceProber{
+		read:       readDeviceDirect,
+		clock:      &clock.SystemClock{},
+		timeout:    deviceReadTimeout,
+		histograms: make(map[string]*latencyHistogram),
+		failures:   make(map[string]uint64),
+		pending:    make(map[string]bool),
+	}
+}
+
+// probe reads the first bytes of a device, recording how long it took, or a failure; it gives up waiting for the read
+// when the context expires, without recording anything, since the device may just not have had its time to reply
+func (p *deviceProber) probe(ctx context.Context, device string, size int64) error {
+	p.Lock()
+	if p.histograms[device] == nil {
+		p.histograms[device] = newLatencyHistogram()
+	}
+	if p.pending[device] {
+		p.failures[device]++
+		p.Unlock()
+		return errors.New("a previous read is still pending")
+	}
+	p.pending[device] = true
+	p.Unlock()
+
+	done := make(chan error, 1)
+	begin := p.clock.Now()
+	go func() {
+		err := p.read(device, size)
+		p.Lock()
+		p.pending[device] = false
+		p.Unlock()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		duration := p.clock.Since(begin)
+		p.Lock()
+		defer p.Unlock()
+		// the exporter not being allowed to open the device is not a failure of the device, so no read is recorded,
+		// and the metrics of the device are not exported, rather than reporting an ever growing number of failures
+		if os.IsPermission(err) {
+			delete(p.histograms, device)
+			delete(p.failures, device)
+			return err
+		}
+		if err != nil {
+			p.failures[device]++
+			return err
+		}
+		p.histograms[device].observe(duration.Seconds())
+		return nil
+	case <-time.After(p.timeout):
+		p.Lock()
+		defer p.Unlock()
+		p.failures[device]++
+		return errors.Errorf("read timed out after %s", p.timeout)
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "read did not complete in time")
+	}
+}
+
+// snapshot returns a copy of the latency histogram of a device, and its number of failed reads
+func (p *deviceProber) snapshot(device string) (latencyHistogram, uint64, bool) {
+	p.Lock()
+	defer p.Unlock()
+	h, ok := p.histograms[device]
+	if !ok {
+		return latencyHistogram{}, 0, false
+	}
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for bound, count := range h.Buckets {
+		buckets[bound] = count
+	}
+	return latencyHistogram{h.Count, h.Sum, buckets}, p.failures[device], true
+}
+
+// readDeviceDirect reads the first bytes of a device with direct I/O, i.e. actually hitting the storage
+func readDeviceDirect(device string, size int64) error {
+	file, err := os.OpenFile(device, os.O_RDONLY|oDirect, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the size must be a multiple of the alignment as well
+	size = (size + directIOAlignment - 1) / directIOAlignment * directIOAlignment
+	raw := make([]byte, size+directIOAlignment)
+	offset := directIOAlignment - int(uintptr(unsafe.Pointer(&raw[0]))%directIOAlignment)
+	buffer := raw[offset%directIOAlignment:][:size]
+
+	_, err = io.ReadFull(file, buffer)
+	return err
+}
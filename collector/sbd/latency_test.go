@@ -0,0 +1,122 @@
+package sbd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/internal/clock"
+)
+
+// a prober whose reads always succeed and take the time of the stopped clock
+func stubDeviceProber() *deviceProber {
+	p := newDeviceProber()
+	p.read = func(string, int64) error { return nil }
+	p.clock = &clock.StoppedClock{}
+	return p
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	h.observe(0.003)
+	h.observe(0.2)
+
+	assert.Equal(t, uint64(2), h.Count)
+	assert.InDelta(t, 0.203, h.Sum, 1e-9)
+	assert.Equal(t, uint64(0), h.Buckets[.001])
+	assert.Equal(t, uint64(1), h.Buckets[.005])
+	assert.Equal(t, uint64(1), h.Buckets[.1])
+	assert.Equal(t, uint64(2), h.Buckets[.25])
+	assert.Equal(t, uint64(2), h.Buckets[5])
+}
+
+func TestDeviceProber(t *testing.T) {
+	p := stubDeviceProber()
+
+	assert.NoError(t, p.probe(context.Background(), "/dev/vdc", 512))
+
+	p.read = func(string, int64) error { return errors.New("I/O error") }
+	assert.EqualError(t, p.probe(context.Background(), "/dev/vdc", 512), "I/O error")
+
+	histogram, failures, ok := p.snapshot("/dev/vdc")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), histogram.Count)
+	assert.Equal(t, 1.234, histogram.Sum)
+	assert.Equal(t, uint64(0), histogram.Buckets[1])
+	assert.Equal(t, uint64(1), histogram.Buckets[2.5])
+	assert.Equal(t, uint64(1), failures)
+
+	_, _, ok = p.snapshot("/dev/vdd")
+	assert.False(t, ok)
+}
+
+func TestDeviceProberPermissionDenied(t *testing.T) {
+	p := stubDeviceProber()
+	assert.NoError(t, p.probe(context.Background(), "/dev/vdc", 512))
+
+	p.read = func(device string, _ int64) error {
+		return &os.PathError{Op: "open", Path: device, Err: syscall.EACCES}
+	}
+	err := p.probe(context.Background(), "/dev/vdc", 512)
+	assert.True(t, os.IsPermission(err))
+
+	// the device is not readable by the exporter, rather than failing
+	_, _, ok := p.snapshot("/dev/vdc")
+	assert.False(t, ok)
+}
+
+func TestDeviceProberTimeout(t *testing.T) {
+	p := stubDeviceProber()
+	p.timeout = time.Millisecond
+	release := make(chan struct{})
+	p.read = func(string, int64) error {
+		<-release
+		return nil
+	}
+
+	assert.EqualError(t, p.probe(context.Background(), "/dev/vdc", 512), "read timed out after 1ms")
+	// the hanging read is not retried until it returns
+	assert.EqualError(t, p.probe(context.Background(), "/dev/vdc", 512), "a previous read is still pending")
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		p.Lock()
+		defer p.Unlock()
+		return !p.pending["/dev/vdc"]
+	}, time.Second, time.Millisecond)
+
+	_, failures, _ := p.snapshot("/dev/vdc")
+	assert.Equal(t, uint64(2), failures)
+}
+
+func TestDeviceProberContextExpired(t *testing.T) {
+	p := stubDeviceProber()
+	release := make(chan struct{})
+	defer close(release)
+	p.read = func(string, int64) error {
+		<-release
+		return nil
+	}
+
+	// the scrape deadline comes before the read timeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err := p.probe(ctx, "/dev/vdc", 512)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, failures, _ := p.snapshot("/dev/vdc")
+	assert.Equal(t, uint64(0), failures)
+}
+
+func TestReadDeviceDirectError(t *testing.T) {
+	err := readDeviceDirect(filepath.Join(t.TempDir(), "nonexistent"), 512)
+
+	assert.Error(t, err)
+	assert.True(t, os.IsNotExist(err))
+}
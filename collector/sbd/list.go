@@ -0,0 +1,40 @@
+package sbd
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// sbdSlot is a node slot of an SBD device, with the message pending for that node
+type sbdSlot struct {
+	Number uint64
+	Node   string
+	// one of `clear`, `test`, `reset`, `off`, `exit` or `crashdump`; anything but `clear` means that a fencing is in progress or was left behind
+	Message string
+}
+
+// parses the slots from this kind of output from `sbd -d <device> list`
+/*
+	0	hana01	clear
+	1	hana02	reset	hana01
+*/
+// the last field is the sender of the message, which is only printed by recent sbd versions, and is ignored
+func parseSbdList(sbdList []byte) ([]sbdSlot, error) {
+	re := regexp.MustCompile(`(?m)^(?P<number>\d+)\s+(?P<node>\S+)\s+(?P<message>\w+)`)
+	matches := re.FindAllStringSubmatch(string(sbdList), -1)
+	slots := make([]sbdSlot, 0, len(matches))
+	for _, match := range matches {
+		number, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse slot number")
+		}
+		slots = append(slots, sbdSlot{
+			Number:  number,
+			Node:    match[2],
+			Message: match[3],
+		})
+	}
+	return slots, nil
+}
@@ -0,0 +1,33 @@
+package sbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSbdList(t *testing.T) {
+	sbdList := []byte("0\thana01\tclear\n1\thana02\treset\thana01\n")
+
+	slots, err := parseSbdList(sbdList)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []sbdSlot{
+		{Number: 0, Node: "hana01", Message: "clear"},
+		{Number: 1, Node: "hana02", Message: "reset"},
+	}, slots)
+}
+
+func TestParseSbdListEmpty(t *testing.T) {
+	slots, err := parseSbdList(nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, slots)
+}
+
+func TestParseSbdListNumberError(t *testing.T) {
+	_, err := parseSbdList([]byte("99999999999999999999\thana01\tclear\n"))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not parse slot number")
+}
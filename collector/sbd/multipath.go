@@ -0,0 +1,158 @@
+package sbd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// the arguments of dmsetup to get the status of all the multipath maps
+var multipathStatusArgs = []string{"status", "--target", "multipath"}
+
+// multipathMapName returns the name of a device-mapper multipath map, e.g. `mpatha`, as `dmsetup` knows it;
+// it returns false if the device is not a multipath map
+func multipathMapName(sysfsPath string, device string) (string, bool) {
+	dm, ok := deviceMapperName(sysfsPath, device)
+	if !ok {
+		return "", false
+	}
+
+	uuid, err := os.ReadFile(filepath.Join(sysfsPath, "block", dm, "dm", "uuid"))
+	if err != nil || !strings.HasPrefix(string(uuid), "mpath-") {
+		return "", false
+	}
+
+	name, err := os.ReadFile(filepath.Join(sysfsPath, "block", dm, "dm", "name"))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(name)), true
+}
+
+// deviceMapperName returns the kernel name of a device-mapper device, e.g. `dm-0`, either from the
+// `/dev/dm-*` node it links to, or from its name in `/dev/mapper`
+func deviceMapperName(sysfsPath string, device string) (string, bool) {
+	if resolved, err := filepath.EvalSymlinks(device); err == nil && strings.HasPrefix(filepath.Base(resolved), "dm-") {
+		return filepath.Base(resolved), true
+	}
+
+	names, err := filepath.Glob(filepath.Join(sysfsPath, "block", "dm-*", "dm", "name"))
+	if err != nil {
+		return "", false
+	}
+	for _, namePath := range names {
+		name, err := os.ReadFile(namePath)
+		if err == nil && strings.TrimSpace(string(name)) == filepath.Base(device) {
+			return filepath.Base(filepath.Dir(filepath.Dir(namePath))), true
+		}
+	}
+	return "", false
+}
+
+// parseMultipathStatus returns the number of paths of a multipath map, by state, i.e. `active` or `failed`,
+// from the output of `dmsetup status --target multipath`, which has one line per map, like this:
+/*
+	mpatha: 0 2097152 multipath 2 0 0 0 1 1 A 0 2 0 8:0 A 0 8:16 F 1
+*/
+// after the name, the start and the length of the map, each line has the status of the multipath target:
+// the feature arguments and the hardware handler arguments, both prefixed by their number, the number of path groups,
+// the active group, then for each group its state, its path selector arguments prefixed by their number, its number of paths
+// and of path selector arguments per path, then for each path its device, its state, i.e. A or F, its failure count
+// and its path selector arguments
+func parseMultipathStatus(dmsetupOutput []byte, name string) (map[string]int, error) {
+	for _, line := range strings.Split(string(dmsetupOutput), "\n") {
+		mapName, status, ok := strings.Cut(line, ": ")
+		if !ok || mapName != name {
+			continue
+		}
+
+		fields := strings.Fields(status)
+		if len(fields) < 3 || fields[2] != "multipath" {
+			return nil, errors.Errorf("unexpected status of %s: %s", name, status)
+		}
+		s := &statusScanner{fields: fields[3:]}
+
+		// the feature arguments, then the hardware handler arguments
+		s.skip(s.number())
+		s.skip(s.number())
+		groups := s.number()
+		// the active group
+		s.skip(1)
+
+		paths := map[string]int{"active": 0, "failed": 0}
+		for group := 0; group < groups && s.err == nil; group++ {
+			// the group state, then the path selector arguments
+			s.skip(1)
+			s.skip(s.number())
+			groupPaths := s.number()
+			pathArgs := s.number()
+			for path := 0; path < groupPaths && s.err == nil; path++ {
+				// the path device
+				s.skip(1)
+				switch s.next() {
+				case "A":
+					paths["active"]++
+				case "F":
+					paths["failed"]++
+				default:
+					s.fail()
+				}
+				// the failure count, then the path selector arguments
+				s.skip(1 + pathArgs)
+			}
+		}
+		if s.err != nil {
+			return nil, errors.Wrapf(s.err, "could not parse the status of %s", name)
+		}
+		return paths, nil
+	}
+
+	return nil, errors.Errorf("could not find the status of %s", name)
+}
+
+// statusScanner reads the space separated fields of a device-mapper status, keeping the first error it runs into
+type statusScanner struct {
+	fields   []string
+	position int
+	err      error
+}
+
+func (s *statusScanner) next() string {
+	if s.err != nil {
+		return ""
+	}
+	if s.position >= len(s.fields) {
+		s.err = errors.New("unexpected end of status")
+		return ""
+	}
+	s.position++
+	return s.fields[s.position-1]
+}
+
+func (s *statusScanner) number() int {
+	field := s.next()
+	if s.err != nil {
+		return 0
+	}
+	number, err := strconv.Atoi(field)
+	if err != nil || number < 0 {
+		s.err = errors.Errorf("unexpected field '%s' at position %d", field, s.position)
+		return 0
+	}
+	return number
+}
+
+func (s *statusScanner) skip(count int) {
+	for i := 0; i < count && s.err == nil; i++ {
+		s.next()
+	}
+}
+
+func (s *statusScanner) fail() {
+	if s.err == nil {
+		s.err = errors.Errorf("unexpected field '%s' at position %d", s.fields[s.position-1], s.position)
+	}
+}
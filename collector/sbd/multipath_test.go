@@ -0,0 +1,60 @@
+package sbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultipathMapName(t *testing.T) {
+	name, ok := multipathMapName("../../test/sysfs", "/dev/mapper/mpatha")
+
+	assert.True(t, ok)
+	assert.Equal(t, "mpatha", name)
+}
+
+func TestMultipathMapNameNotMultipath(t *testing.T) {
+	_, ok := multipathMapName("../../test/sysfs", "/dev/vdc")
+
+	assert.False(t, ok)
+}
+
+func TestParseMultipathStatus(t *testing.T) {
+	dmsetupOutput := []byte(`mpatha: 0 2097152 multipath 2 0 0 0 2 1 A 0 1 2 8:0 A 0 0 1 E 0 1 2 8:16 F 1 0 1
+mpathb: 0 2097152 multipath 2 0 0 0 1 1 A 0 1 0 8:32 A 0
+`)
+
+	paths, err := parseMultipathStatus(dmsetupOutput, "mpatha")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"active": 1, "failed": 1}, paths)
+
+	paths, err = parseMultipathStatus(dmsetupOutput, "mpathb")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"active": 1, "failed": 0}, paths)
+}
+
+func TestParseMultipathStatusWithFeatures(t *testing.T) {
+	dmsetupOutput := []byte("mpatha: 0 2097152 multipath 2 0 0 1 0 1 1 A 0 2 0 8:0 A 0 8:16 A 0\n")
+
+	paths, err := parseMultipathStatus(dmsetupOutput, "mpatha")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"active": 2, "failed": 0}, paths)
+}
+
+func TestParseMultipathStatusNotFound(t *testing.T) {
+	_, err := parseMultipathStatus([]byte("mpathb: 0 2097152 multipath 2 0 0 0 1 1 A 0 1 0 8:32 A 0\n"), "mpatha")
+
+	assert.EqualError(t, err, "could not find the status of mpatha")
+}
+
+func TestParseMultipathStatusTruncated(t *testing.T) {
+	_, err := parseMultipathStatus([]byte("mpatha: 0 2097152 multipath 2 0 0 0 1 1 A 0 2 0 8:0 A 0\n"), "mpatha")
+
+	assert.EqualError(t, err, "could not parse the status of mpatha: unexpected end of status")
+}
+
+func TestParseMultipathStatusInvalidPathState(t *testing.T) {
+	_, err := parseMultipathStatus([]byte("mpatha: 0 2097152 multipath 2 0 0 0 1 1 A 0 1 0 8:0 X 0\n"), "mpatha")
+
+	assert.EqualError(t, err, "could not parse the status of mpatha: unexpected field 'X' at position 12")
+}
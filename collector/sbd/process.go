@@ -0,0 +1,155 @@
+package sbd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+// the systemd unit of the sbd daemon
+const sbdUnit = "sbd.service"
+
+// the clock ticks per second of the process start times in /proc, which the kernel always exposes as USER_HZ, i.e. 100
+const userHz = 100
+
+// the roles of the sbd processes
+const (
+	roleInquisitor       = "inquisitor"
+	roleServant          = "servant"
+	rolePacemakerWatcher = "pacemaker_watcher"
+	roleClusterWatcher   = "cluster_watcher"
+)
+
+// sbdProcess is one of the processes of the sbd daemon, identified by the title it sets for itself, e.g. `sbd: inquisitor`
+type sbdProcess struct {
+	Pid  int
+	Role string
+	// the SBD device monitored by a servant; empty for the other roles
+	Device string
+	// seconds since the process started
+	Uptime float64
+}
+
+// parseSbdProcessTitle extracts the role from a process title, like these:
+/*
+	sbd: inquisitor
+	sbd: watcher: /dev/vdc - slot: 0 - uuid: 1ed3171d-066d-47ca-8f76-aec25d9efed4
+	sbd: watcher: Pacemaker
+	sbd: watcher: Cluster
+*/
+func parseSbdProcessTitle(title string) (role string, device string, ok bool) {
+	title = strings.TrimSpace(title)
+	switch {
+	case title == "sbd: inquisitor":
+		return roleInquisitor, "", true
+	case title == "sbd: watcher: Pacemaker":
+		return rolePacemakerWatcher, "", true
+	case title == "sbd: watcher: Cluster":
+		return roleClusterWatcher, "", true
+	case strings.HasPrefix(title, "sbd: watcher: "):
+		device, _, _ := strings.Cut(strings.TrimPrefix(title, "sbd: watcher: "), " - ")
+		return roleServant, device, true
+	}
+	return "", "", false
+}
+
+// findSbdProcesses lists the sbd processes in procfs, with their roles and uptimes
+func findSbdProcesses(procfsPath string) ([]sbdProcess, error) {
+	uptimeRaw, err := os.ReadFile(filepath.Join(procfsPath, "uptime"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the system uptime")
+	}
+	uptimeFields := strings.Fields(string(uptimeRaw))
+	if len(uptimeFields) == 0 {
+		return nil, errors.New("empty system uptime")
+	}
+	systemUptime, err := strconv.ParseFloat(uptimeFields[0], 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse the system uptime")
+	}
+
+	comms, err := filepath.Glob(filepath.Join(procfsPath, "[0-9]*", "comm"))
+	if err != nil {
+		return nil, err
+	}
+	var processes []sbdProcess
+	for _, commPath := range comms {
+		dir := filepath.Dir(commPath)
+		// processes may exit while we look at them, so any error just means the process is gone
+		comm, err := os.ReadFile(commPath)
+		if err != nil || strings.TrimSpace(string(comm)) != "sbd" {
+			continue
+		}
+		// the title overwrites the arguments, and it's padded with NUL bytes
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		role, device, ok := parseSbdProcessTitle(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+		if !ok {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue
+		}
+		startTime, err := parseProcessStartTime(stat)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse %s", filepath.Join(dir, "stat"))
+		}
+		pid, _ := strconv.Atoi(filepath.Base(dir))
+
+		processes = append(processes, sbdProcess{
+			Pid:    pid,
+			Role:   role,
+			Device: device,
+			Uptime: systemUptime - startTime,
+		})
+	}
+	return processes, nil
+}
+
+// parseProcessStartTime returns the start time of a process in seconds since boot, from the 22nd field of /proc/<pid>/stat;
+// the fields are counted after the command name, which is enclosed in parentheses and may contain spaces
+func parseProcessStartTime(stat []byte) (float64, error) {
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, errors.New("missing command name")
+	}
+	// the fields after the command name start from the 3rd one
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return 0, errors.New("too few fields")
+	}
+	ticks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not parse the start time")
+	}
+	return float64(ticks) / userHz, nil
+}
+
+// isSbdEnabled tells whether the sbd daemon is enabled in systemd, i.e. whether SBD is configured at all,
+// since its configuration file is installed along with it even when it's not used
+func isSbdEnabled(ctx context.Context, executor collector.Executor, systemctlPath string) (bool, error) {
+	// systemctl exits with an error when the unit is not enabled, or not even installed, but it still prints its state
+	stateRaw, err := collector.RunCommand(ctx, executor, systemctlPath, "is-enabled", sbdUnit)
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return false, err
+		}
+	}
+
+	switch strings.TrimSpace(string(stateRaw)) {
+	case "enabled", "enabled-runtime":
+		return true, nil
+	}
+	return false, nil
+}
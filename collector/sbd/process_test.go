@@ -0,0 +1,83 @@
+package sbd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+)
+
+func TestParseSbdProcessTitle(t *testing.T) {
+	testCases := []struct {
+		title  string
+		role   string
+		device string
+		ok     bool
+	}{
+		{"sbd: inquisitor     ", roleInquisitor, "", true},
+		{"sbd: watcher: /dev/vdc - slot: 0 - uuid: 1ed3171d-066d-47ca-8f76-aec25d9efed4", roleServant, "/dev/vdc", true},
+		{"sbd: watcher: Pacemaker", rolePacemakerWatcher, "", true},
+		{"sbd: watcher: Cluster", roleClusterWatcher, "", true},
+		{"/usr/sbin/sbd -d /dev/vdc list", "", "", false},
+	}
+
+	for _, testCase := range testCases {
+		role, device, ok := parseSbdProcessTitle(testCase.title)
+		assert.Equal(t, testCase.ok, ok, testCase.title)
+		assert.Equal(t, testCase.role, role, testCase.title)
+		assert.Equal(t, testCase.device, device, testCase.title)
+	}
+}
+
+func TestParseProcessStartTime(t *testing.T) {
+	// the command name may contain spaces and parentheses
+	stat := []byte("4242 (sbd (x) y) S 1 4242 4242 0 -1 4194560 1000 0 0 0 10 20 0 0 -2 0 1 0 123456 123456789 1234")
+
+	startTime, err := parseProcessStartTime(stat)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1234.56, startTime)
+}
+
+func TestParseProcessStartTimeErrors(t *testing.T) {
+	_, err := parseProcessStartTime([]byte("4242 sbd S 1"))
+	assert.EqualError(t, err, "missing command name")
+
+	_, err = parseProcessStartTime([]byte("4242 (sbd) S 1"))
+	assert.EqualError(t, err, "too few fields")
+}
+
+func TestFindSbdProcesses(t *testing.T) {
+	processes, err := findSbdProcesses("../../test/procfs")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []sbdProcess{
+		{Pid: 4242, Role: roleInquisitor, Uptime: 12325.67},
+		{Pid: 4243, Role: roleServant, Device: "/dev/vdc", Uptime: 12325.17},
+		{Pid: 4244, Role: rolePacemakerWatcher, Uptime: 12325.17},
+		{Pid: 4245, Role: roleClusterWatcher, Uptime: 12325.17},
+	}, processes)
+}
+
+func TestFindSbdProcessesWithoutProcfs(t *testing.T) {
+	_, err := findSbdProcesses("../../test/nonexistent")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not read the system uptime")
+}
+
+func TestIsSbdEnabled(t *testing.T) {
+	enabled, err := isSbdEnabled(context.Background(), collector.DirectExecutor{}, "../../test/fake_systemctl.sh")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	// systemctl exits with an error and prints nothing when the unit is not installed
+	enabled, err = isSbdEnabled(context.Background(), collector.DirectExecutor{}, "false")
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	_, err = isSbdEnabled(context.Background(), collector.DirectExecutor{}, "../../test/nonexistent")
+	assert.Error(t, err)
+}
@@ -1,13 +1,11 @@
 package sbd
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
-	"regexp"
 	"strconv"
-	"strings"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -15,6 +13,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/ClusterLabs/ha_cluster_exporter/collector"
+	"github.com/ClusterLabs/ha_cluster_exporter/collector/pacemaker/cib"
 )
 
 const subsystem = "sbd"
@@ -22,8 +21,9 @@ const subsystem = "sbd"
 const SBD_STATUS_UNHEALTHY = "unhealthy"
 const SBD_STATUS_HEALTHY = "healthy"
 
-// NewCollector create a new sbd collector
-func NewCollector(sbdPath string, sbdConfigPath string, timestamps bool, logger log.Logger) (*sbdCollector, error) {
+// NewCollector create a new sbd collector; cibadmin is only used to check the SBD timeouts against the Pacemaker ones,
+// dmsetup to check the paths of the devices which are multipath maps, and systemctl to check whether SBD runs diskless
+func NewCollector(sbdPath string, sbdConfigPath string, cibAdminPath string, dmsetupPath string, systemctlPath string, executor collector.Executor, timestamps bool, logger log.Logger) (*sbdCollector, error) {
 	err := checkArguments(sbdPath, sbdConfigPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not initialize '%s' collector", subsystem)
@@ -33,10 +33,44 @@ func NewCollector(sbdPath string, sbdConfigPath string, timestamps bool, logger
 		collector.NewDefaultCollector(subsystem, timestamps, logger),
 		sbdPath,
 		sbdConfigPath,
+		dmsetupPath,
+		executor,
+		cib.NewCibAdminParser(cibAdminPath, executor),
+		newDeviceProber(),
+		systemctlPath,
+		"/sys",
+		"/proc",
 	}
 
 	c.SetDescriptor("devices", "SBD devices; one line per device", []string{"device", "status"})
 	c.SetDescriptor("timeouts", "SBD timeouts for each device and type", []string{"device", "type"})
+	c.SetDescriptor("device_info", "The metadata header of each SBD device; one line per device", []string{"device", "uuid", "version"})
+	c.SetDescriptor("device_slots", "The number of node slots in each SBD device", []string{"device"})
+	c.SetDescriptor("device_sector_size_bytes", "The sector size of each SBD device", []string{"device"})
+	c.SetDescriptor("config_info", "The SBD configuration, with the defaults of the unset options", []string{"device", "pacemaker", "startmode", "delay_start", "watchdog_dev", "watchdog_timeout", "timeout_action", "move_to_root_cgroup", "sync_resource_startup", "opts"})
+	c.SetDescriptor("config_pacemaker", "Whether the SBD integration with Pacemaker is enabled", nil)
+	c.SetDescriptor("config_sync_resource_startup", "Whether Pacemaker waits for SBD to be ready before starting resources", nil)
+	c.SetDescriptor("config_delay_start", "The delay of SBD on startup; 0 for none, 1 for msgwait, otherwise in seconds", nil)
+	c.SetDescriptor("diskless", "Whether SBD runs in diskless mode, i.e. with no devices and the watchdog only", nil)
+	c.SetDescriptor("watchdog_info", "The watchdog device used by SBD; one line per device", []string{"device", "name", "identity"})
+	c.SetDescriptor("watchdog_configured_timeout_seconds", "The watchdog timeout configured for SBD", []string{"device"})
+	c.SetDescriptor("watchdog_timeout_seconds", "The current timeout of the watchdog device", []string{"device"})
+	c.SetDescriptor("watchdog_timeleft_seconds", "The time left before the watchdog device resets the node", []string{"device"})
+	c.SetDescriptor("watchdog_pretimeout_seconds", "The pretimeout of the watchdog device", []string{"device"})
+	c.SetDescriptor("watchdog_status", "The driver status bitmask of the watchdog device", []string{"device"})
+	c.SetDescriptor("watchdog_nowayout", "Whether the watchdog device cannot be stopped once started", []string{"device"})
+	c.SetDescriptor("watchdog_active", "Whether the watchdog device is started", []string{"device"})
+	c.SetDescriptor("watchdog_open", "Whether the watchdog device is held open by the SBD daemon", []string{"device"})
+	c.SetDescriptor("timeout_checks", "Whether the SBD timeouts are consistent with each other and with the Pacemaker ones; 1 line per rule, per device", []string{"rule", "device"})
+	c.SetDescriptor("timeout_check_value_seconds", "The timeout checked by each SBD timeout rule; 1 line per rule, per device", []string{"rule", "device"})
+	c.SetDescriptor("timeout_check_minimum_seconds", "The minimum timeout required by each SBD timeout rule; 1 line per rule, per device", []string{"rule", "device"})
+	c.SetDescriptor("device_read_duration_seconds", "The latency of direct reads of the header and slots of each SBD device", []string{"device"})
+	c.SetDescriptor("device_read_failures_total", "The number of failed or timed out direct reads of each SBD device", []string{"device"})
+	c.SetDescriptor("device_paths", "The number of paths of each multipath SBD device, by state", []string{"device", "state"})
+	c.SetDescriptor("process_up", "Whether each of the expected SBD processes is running; 1 line per role, per device", []string{"role", "device"})
+	c.SetDescriptor("process_uptime_seconds", "How long each of the running SBD processes has been running", []string{"role", "device"})
+	c.SetDescriptor("slots", "The node slots allocated in each SBD device, with their pending message; one line per device and slot", []string{"device", "slot", "node", "message"})
+	c.SetDescriptor("node_slot_allocated", "Whether each cluster node has a slot allocated in each SBD device; one line per device and node", []string{"device", "node"})
 
 	return c, nil
 }
@@ -55,39 +89,146 @@ type sbdCollector struct {
 	collector.DefaultCollector
 	sbdPath       string
 	sbdConfigPath string
+	dmsetupPath   string
+	executor      collector.Executor
+	cibParser     cib.Parser
+	prober        *deviceProber
+	systemctlPath string
+	sysfsPath     string
+	procfsPath    string
 }
 
-func (c *sbdCollector) CollectWithError(ch chan<- prometheus.Metric) error {
+func (c *sbdCollector) CollectWithError(ctx context.Context, ch chan<- prometheus.Metric) error {
 	level.Debug(c.Logger).Log("msg", "Collecting pacemaker metrics...")
 
-	sbdConfiguration, err := readSdbFile(c.sbdConfigPath)
+	sbdConfigRaw, err := readSdbFile(c.sbdConfigPath)
 	if err != nil {
 		return err
 	}
 
-	sbdDevices := getSbdDevices(sbdConfiguration)
+	sbdConfig := parseSbdConfig(sbdConfigRaw, c.Logger)
+	c.collectConfig(sbdConfig, ch)
 
-	sbdStatuses := c.getSbdDeviceStatuses(sbdDevices)
-	for sbdDev, sbdStatus := range sbdStatuses {
-		ch <- c.MakeGaugeMetric("devices", 1, sbdDev, sbdStatus)
+	sbdDevices := sbdConfig.devices()
+
+	// without any device, SBD relies on the watchdog alone, which is what makes its state critical;
+	// that is, unless SBD is not configured at all, in which case its configuration file has no device either
+	diskless := false
+	if len(sbdDevices) == 0 {
+		diskless, err = isSbdEnabled(ctx, c.executor, c.systemctlPath)
+	}
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not check whether sbd is enabled", "err", err)
+	} else {
+		ch <- c.MakeGaugeMetric("diskless", boolToFloat(diskless))
 	}
+	c.collectWatchdog(sbdConfig, ch)
+	c.collectProcesses(sbdConfig, ch)
+
+	// the devices are read concurrently, along with the CIB, which is only needed to check the timeouts and the slots
+	var CIB *cib.Root
+	headers := make([]*sbdHeader, len(sbdDevices))
+	slots := make([][]sbdSlot, len(sbdDevices))
+	workers := collector.NewWorkers(ctx, collector.DefaultParallelism)
+	workers.Go(func(ctx context.Context) error {
+		// without the CIB, the timeouts are not checked against the Pacemaker ones, and the nodes without a slot are unknown
+		root, err := c.cibParser.Parse(ctx)
+		if err != nil {
+			level.Warn(c.Logger).Log("msg", "could not read the CIB to check the SBD timeouts and slots", "err", err)
+			return nil
+		}
+		CIB = &root
+		return nil
+	})
+	for i, sbdDev := range sbdDevices {
+		workers.Go(func(ctx context.Context) error {
+			headers[i], slots[i] = c.collectDevice(ctx, sbdDev, ch)
+			return nil
+		})
+	}
+	err = workers.Wait()
 
-	sbdWatchdogs, sbdMsgWaits := c.getSbdTimeouts(sbdDevices)
-	for sbdDev, sbdWatchdog := range sbdWatchdogs {
-		ch <- c.MakeGaugeMetric("timeouts", sbdWatchdog, sbdDev, "watchdog")
+	sbdHeaders := make(map[string]sbdHeader)
+	for i, sbdDev := range sbdDevices {
+		if headers[i] != nil {
+			sbdHeaders[sbdDev] = *headers[i]
+		}
+		if slots[i] != nil {
+			c.collectSlots(sbdDev, slots[i], CIB, ch)
+		}
+	}
+	if len(sbdDevices) > 0 || diskless {
+		c.collectTimeoutChecks(sbdHeaders, sbdConfig, CIB, ch)
 	}
 
-	for sbdDev, sbdMsgWait := range sbdMsgWaits {
-		ch <- c.MakeGaugeMetric("timeouts", sbdMsgWait, sbdDev, "msgwait")
+	// the devices that couldn't be read before the deadline are missing
+	if err != nil {
+		return errors.Wrap(err, "could not read all the sbd devices")
 	}
 
 	return nil
 }
 
+// collectDevice collects the metrics of a single device, and returns its header, if it could be dumped,
+// and its slots, if they could be listed
+func (c *sbdCollector) collectDevice(ctx context.Context, sbdDev string, ch chan<- prometheus.Metric) (*sbdHeader, []sbdSlot) {
+	// the header is dumped only once per device, to limit the I/O on the shared storage
+	sbdDump, err := collector.RunCommand(ctx, c.executor, c.sbdPath, "-d", sbdDev, "dump")
+
+	// in case of error the device is not healthy, and it's not read any further, not to add I/O to a failing storage
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not dump sbd device", "device", sbdDev, "err", err)
+		ch <- c.MakeGaugeMetric("devices", 1, sbdDev, SBD_STATUS_UNHEALTHY)
+		return nil, nil
+	}
+	ch <- c.MakeGaugeMetric("devices", 1, sbdDev, SBD_STATUS_HEALTHY)
+
+	sbdHeader, err := parseSbdDump(sbdDump)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not parse sbd dump", "device", sbdDev, "err", err)
+		return nil, nil
+	}
+	c.collectHeader(sbdDev, sbdHeader, ch)
+	c.collectDeviceHealth(ctx, sbdDev, sbdHeader, ch)
+
+	sbdList, err := collector.RunCommand(ctx, c.executor, c.sbdPath, "-d", sbdDev, "list")
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not list sbd slots", "device", sbdDev, "err", err)
+		return &sbdHeader, nil
+	}
+	sbdSlots, err := parseSbdList(sbdList)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not parse sbd slots", "device", sbdDev, "err", err)
+		return &sbdHeader, nil
+	}
+
+	return &sbdHeader, sbdSlots
+}
+
+// collectSlots collects the slots allocated in a device and, when the CIB is available, whether each cluster node has one
+func (c *sbdCollector) collectSlots(sbdDev string, sbdSlots []sbdSlot, CIB *cib.Root, ch chan<- prometheus.Metric) {
+	allocated := make(map[string]bool)
+	for _, slot := range sbdSlots {
+		ch <- c.MakeGaugeMetric("slots", 1, sbdDev, strconv.FormatUint(slot.Number, 10), slot.Node, slot.Message)
+		allocated[slot.Node] = true
+	}
+
+	if CIB == nil {
+		return
+	}
+	for _, node := range CIB.Configuration.Nodes {
+		// remote nodes usually don't run SBD themselves, so they are not expected to have a slot
+		if node.Type == "remote" {
+			continue
+		}
+		ch <- c.MakeGaugeMetric("node_slot_allocated", boolToFloat(allocated[node.Uname]), sbdDev, node.Uname)
+	}
+}
+
 func (c *sbdCollector) Collect(ch chan<- prometheus.Metric) {
 	level.Debug(c.Logger).Log("msg", "Collecting pacemaker metrics...")
 
-	err := c.CollectWithError(ch)
+	err := c.CollectWithError(context.Background(), ch)
 	if err != nil {
 		level.Warn(c.Logger).Log("msg", c.GetSubsystem()+" collector scrape failed", "err", err)
 	}
@@ -108,81 +249,198 @@ func readSdbFile(sbdConfigPath string) ([]byte, error) {
 	return sbdConfigRaw, nil
 }
 
-// retrieve a list of sbd devices from the config file contents
-func getSbdDevices(sbdConfigRaw []byte) []string {
-	// The following regex matches lines like SBD_DEVICE="/dev/foo" or SBD_DEVICE=/dev/foo;/dev/bar
-	// It captures all the colon separated device names, without double quotes, into a capture group
-	// It allows for free indentation, trailing spaces and end of lines, and it will ignore commented lines
-	// Unbalanced double quotes are not checked and they will still produce a match
-	// If multiple matching lines are present, only the first will be used
-	// The single device name pattern is `[\w-/]+`, which is pretty relaxed
-	regex := regexp.MustCompile(`(?m)^\s*SBD_DEVICE="?((?:[\w-/]+;?\s?)+)"?\s*$`)
-	sbdDevicesLine := regex.FindStringSubmatch(string(sbdConfigRaw))
+func (c *sbdCollector) collectConfig(sbdConfig sbdConfig, ch chan<- prometheus.Metric) {
+	ch <- c.MakeGaugeMetric("config_info", 1,
+		sbdConfig.get("SBD_DEVICE"),
+		sbdConfig.get("SBD_PACEMAKER"),
+		sbdConfig.get("SBD_STARTMODE"),
+		sbdConfig.get("SBD_DELAY_START"),
+		sbdConfig.get("SBD_WATCHDOG_DEV"),
+		sbdConfig.get("SBD_WATCHDOG_TIMEOUT"),
+		sbdConfig.get("SBD_TIMEOUT_ACTION"),
+		sbdConfig.get("SBD_MOVE_TO_ROOT_CGROUP"),
+		sbdConfig.get("SBD_SYNC_RESOURCE_STARTUP"),
+		sbdConfig.get("SBD_OPTS"),
+	)
+
+	for metric, option := range map[string]string{
+		"config_pacemaker":             "SBD_PACEMAKER",
+		"config_sync_resource_startup": "SBD_SYNC_RESOURCE_STARTUP",
+		"config_delay_start":           "SBD_DELAY_START",
+	} {
+		value, err := sbdConfig.number(option)
+		if err != nil {
+			level.Warn(c.Logger).Log("msg", "could not parse sbd config", "err", err)
+			continue
+		}
+		ch <- c.MakeGaugeMetric(metric, value)
+	}
+}
 
-	// if SBD_DEVICE line could not be found, return 0 devices
-	if sbdDevicesLine == nil {
-		return nil
+func (c *sbdCollector) collectWatchdog(sbdConfig sbdConfig, ch chan<- prometheus.Metric) {
+	device := sbdConfig.get("SBD_WATCHDOG_DEV")
+	if device == noWatchdogDevice {
+		return
 	}
 
-	// split the first capture group, e.g. `/dev/foo;/dev/bar`; the 0th element is always the whole line
-	sbdDevices := strings.Split(strings.TrimRight(sbdDevicesLine[1], ";"), ";")
-	for i, _ := range sbdDevices {
-		sbdDevices[i] = strings.TrimSpace(sbdDevices[i])
+	timeout, err := sbdConfig.number("SBD_WATCHDOG_TIMEOUT")
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not parse sbd config", "err", err)
+	} else {
+		ch <- c.MakeGaugeMetric("watchdog_configured_timeout_seconds", timeout, device)
 	}
 
-	return sbdDevices
+	open, err := isWatchdogOpenBySbd(c.procfsPath, device)
+	if os.IsPermission(err) {
+		level.Warn(c.Logger).Log("msg", "not allowed to inspect the sbd processes, whether the watchdog is open is unavailable", "device", device, "err", err)
+	} else if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not check whether the watchdog is open", "device", device, "err", err)
+	} else {
+		ch <- c.MakeGaugeMetric("watchdog_open", boolToFloat(open), device)
+	}
+
+	watchdog, err := readWatchdog(c.sysfsPath, device)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not read the watchdog state", "device", device, "err", err)
+		return
+	}
+	ch <- c.MakeGaugeMetric("watchdog_info", 1, device, watchdog.Name, watchdog.Identity)
+	if watchdog.State != "" {
+		ch <- c.MakeGaugeMetric("watchdog_active", boolToFloat(watchdog.State == "active"), device)
+	}
+	for attribute, value := range watchdog.Attributes {
+		switch attribute {
+		case "timeout", "timeleft", "pretimeout":
+			ch <- c.MakeGaugeMetric("watchdog_"+attribute+"_seconds", value, device)
+		default:
+			ch <- c.MakeGaugeMetric("watchdog_"+attribute, value, device)
+		}
+	}
 }
 
-// this function takes a list of sbd devices and returns
-// a map of SBD device names with 1 if healthy, 0 if not
-func (c *sbdCollector) getSbdDeviceStatuses(sbdDevices []string) map[string]string {
-	sbdStatuses := make(map[string]string)
-	for _, sbdDev := range sbdDevices {
-		_, err := exec.Command(c.sbdPath, "-d", sbdDev, "dump").Output()
+func (c *sbdCollector) collectDeviceHealth(ctx context.Context, sbdDev string, sbdHeader sbdHeader, ch chan<- prometheus.Metric) {
+	// the header is followed by two sectors per slot, the slot itself and its message; SBD reads all of them, so that's what we time
+	sectorSize, slots := sbdHeader.SectorSize, sbdHeader.Slots
+	if sectorSize == 0 || slots == 0 {
+		sectorSize, slots = 512, 255
+	}
+	err := c.prober.probe(ctx, sbdDev, int64((1+2*slots)*sectorSize))
+	if os.IsPermission(err) {
+		level.Warn(c.Logger).Log("msg", "not allowed to read sbd device, its read latency is unavailable", "device", sbdDev, "err", err)
+	} else if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not read sbd device", "device", sbdDev, "err", err)
+	}
 
-		// in case of error the device is not healthy
-		if err != nil {
-			sbdStatuses[sbdDev] = SBD_STATUS_UNHEALTHY
-		} else {
-			sbdStatuses[sbdDev] = SBD_STATUS_HEALTHY
-		}
+	if histogram, failures, ok := c.prober.snapshot(sbdDev); ok {
+		ch <- c.MakeHistogramMetric("device_read_duration_seconds", histogram.Count, histogram.Sum, histogram.Buckets, sbdDev)
+		ch <- c.MakeCounterMetric("device_read_failures_total", float64(failures), sbdDev)
 	}
 
-	return sbdStatuses
+	name, ok := multipathMapName(c.sysfsPath, sbdDev)
+	if !ok {
+		return
+	}
+	// the state of the paths is the one device-mapper uses them by, which is only known to the multipath target
+	dmsetupStatus, err := collector.RunCommand(ctx, c.executor, c.dmsetupPath, multipathStatusArgs...)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not read the multipath status", "device", sbdDev, "err", err)
+		return
+	}
+	paths, err := parseMultipathStatus(dmsetupStatus, name)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not parse the multipath status", "device", sbdDev, "err", err)
+		return
+	}
+	for state, count := range paths {
+		ch <- c.MakeGaugeMetric("device_paths", float64(count), sbdDev, state)
+	}
 }
 
-// for each sbd device, extract the watchdog and msgwait timeout via regex
-func (c *sbdCollector) getSbdTimeouts(sbdDevices []string) (map[string]float64, map[string]float64) {
-	sbdWatchdogs := make(map[string]float64)
-	sbdMsgWaits := make(map[string]float64)
-	for _, sbdDev := range sbdDevices {
-		sbdDump, _ := exec.Command(c.sbdPath, "-d", sbdDev, "dump").Output()
-
-		regexW := regexp.MustCompile(`Timeout \(msgwait\)  *: \d+`)
-		regex := regexp.MustCompile(`Timeout \(watchdog\)  *: \d+`)
+func (c *sbdCollector) collectProcesses(sbdConfig sbdConfig, ch chan<- prometheus.Metric) {
+	processes, err := findSbdProcesses(c.procfsPath)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not inspect the sbd processes", "err", err)
+		return
+	}
 
-		msgWaitLine := regexW.FindStringSubmatch(string(sbdDump))
-		watchdogLine := regex.FindStringSubmatch(string(sbdDump))
+	// the inquisitor always runs, with one servant per device, plus the watchers of the Pacemaker integration
+	type processKey struct{ role, device string }
+	missing := map[processKey]bool{{roleInquisitor, ""}: true}
+	for _, device := range sbdConfig.devices() {
+		missing[processKey{roleServant, device}] = true
+	}
+	if pacemaker, err := sbdConfig.number("SBD_PACEMAKER"); err == nil && pacemaker == 1 {
+		missing[processKey{rolePacemakerWatcher, ""}] = true
+		missing[processKey{roleClusterWatcher, ""}] = true
+	}
 
-		if watchdogLine == nil || msgWaitLine == nil {
+	for _, process := range processes {
+		key := processKey{process.Role, process.Device}
+		// a restarted servant may briefly overlap with the old one, so we only report the first process found
+		if isMissing, known := missing[key]; known && !isMissing {
 			continue
 		}
+		missing[key] = false
+		ch <- c.MakeGaugeMetric("process_up", 1, process.Role, process.Device)
+		ch <- c.MakeGaugeMetric("process_uptime_seconds", process.Uptime, process.Role, process.Device)
+	}
+	for key, isMissing := range missing {
+		if isMissing {
+			ch <- c.MakeGaugeMetric("process_up", 0, key.role, key.device)
+		}
+	}
+}
 
-		// get the timeout from the line
-		regexNumber := regexp.MustCompile(`\d+`)
-		watchdogTimeout := regexNumber.FindString(string(watchdogLine[0]))
-		msgWaitTimeout := regexNumber.FindString(string(msgWaitLine[0]))
+func (c *sbdCollector) collectTimeoutChecks(sbdHeaders map[string]sbdHeader, sbdConfig sbdConfig, CIB *cib.Root, ch chan<- prometheus.Metric) {
+	checks, err := checkTimeouts(sbdHeaders, sbdConfig, CIB)
+	if err != nil {
+		level.Warn(c.Logger).Log("msg", "could not check the SBD timeouts", "err", err)
+		return
+	}
+	for _, check := range checks {
+		ch <- c.MakeGaugeMetric("timeout_checks", boolToFloat(check.passed()), check.Rule, check.Device)
+		ch <- c.MakeGaugeMetric("timeout_check_value_seconds", check.Value, check.Rule, check.Device)
+		ch <- c.MakeGaugeMetric("timeout_check_minimum_seconds", check.Minimum, check.Rule, check.Device)
+	}
+}
 
-		// map the timeout to the device
-		if s, err := strconv.ParseFloat(watchdogTimeout, 64); err == nil {
-			sbdWatchdogs[sbdDev] = s
-		}
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
 
-		// map the timeout to the device
-		if s, err := strconv.ParseFloat(msgWaitTimeout, 64); err == nil {
-			sbdMsgWaits[sbdDev] = s
-		}
+func (c *sbdCollector) collectHeader(sbdDev string, sbdHeader sbdHeader, ch chan<- prometheus.Metric) {
+	for timeoutType, timeout := range sbdHeader.Timeouts {
+		ch <- c.MakeGaugeMetric("timeouts", timeout, sbdDev, timeoutType)
+	}
+
+	// an empty UUID means that the header could not be read at all
+	if sbdHeader.Uuid == "" {
+		return
+	}
+	ch <- c.MakeGaugeMetric("device_info", 1, sbdDev, sbdHeader.Uuid, sbdHeader.Version)
+	ch <- c.MakeGaugeMetric("device_slots", float64(sbdHeader.Slots), sbdDev)
+	ch <- c.MakeGaugeMetric("device_sector_size_bytes", float64(sbdHeader.SectorSize), sbdDev)
+}
+
+// Commands returns the commands the collector runs, with their arguments, e.g. to allow them in a sudoers policy;
+// they depend on the devices currently configured, so they must be updated whenever these change
+func Commands(sbdPath string, sbdConfigPath string, cibAdminPath string, dmsetupPath string, systemctlPath string) ([][]string, error) {
+	sbdConfigRaw, err := readSdbFile(sbdConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	// any malformed line is skipped by sbd as well, so it can't configure any device
+	sbdConfig := parseSbdConfig(sbdConfigRaw, log.NewNopLogger())
 
+	var commands [][]string
+	for _, sbdDev := range sbdConfig.devices() {
+		commands = append(commands, []string{sbdPath, "-d", sbdDev, "dump"}, []string{sbdPath, "-d", sbdDev, "list"})
 	}
-	return sbdWatchdogs, sbdMsgWaits
+	commands = append(commands, []string{cibAdminPath, "--query", "--local"})
+	commands = append(commands, append([]string{dmsetupPath}, multipathStatusArgs...))
+	commands = append(commands, []string{systemctlPath, "is-enabled", sbdUnit})
+	return commands, nil
 }
@@ -1,11 +1,17 @@
 package sbd
 
 import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 	assertcustom "github.com/ClusterLabs/ha_cluster_exporter/internal/assert"
 )
 
@@ -118,7 +124,7 @@ func TestGetSbdDevicesWithoutDoubleQuotes(t *testing.T) {
 	 SBD_DEVICE=/dev/vda;/dev/vdb;/dev/vdc
 `
 
-	sbdDevices := getSbdDevices([]byte(sbdConfig))
+	sbdDevices := parseSbdConfig([]byte(sbdConfig), log.NewNopLogger()).devices()
 
 	assert.Len(t, sbdDevices, 3)
 	assert.Equal(t, "/dev/vda", sbdDevices[0])
@@ -150,7 +156,7 @@ func TestGetSbdDevicesWithDoubleQuotes(t *testing.T) {
 	 #
 	 SBD_OPTS=`
 
-	sbdDevices := getSbdDevices([]byte(sbdConfig))
+	sbdDevices := parseSbdConfig([]byte(sbdConfig), log.NewNopLogger()).devices()
 
 	assert.Len(t, sbdDevices, 3)
 	assert.Equal(t, "/dev/vda", sbdDevices[0])
@@ -169,7 +175,7 @@ func TestOnlyOneDeviceSbd(t *testing.T) {
 	 ## Default: "flush,reboot"
 `
 
-	sbdDevices := getSbdDevices([]byte(sbdConfig))
+	sbdDevices := parseSbdConfig([]byte(sbdConfig), log.NewNopLogger()).devices()
 
 	assert.Len(t, sbdDevices, 1)
 	assert.Equal(t, "/dev/vdc", sbdDevices[0])
@@ -180,7 +186,7 @@ func TestSbdDeviceParserWithFullCommentBeforeActualSetting(t *testing.T) {
 # SBD_DEVICE=/dev/foo
 SBD_DEVICE=/dev/vdc;/dev/vdd`
 
-	sbdDevices := getSbdDevices([]byte(sbdConfig))
+	sbdDevices := parseSbdConfig([]byte(sbdConfig), log.NewNopLogger()).devices()
 
 	assert.Len(t, sbdDevices, 2)
 	assert.Equal(t, "/dev/vdc", sbdDevices[0])
@@ -190,7 +196,7 @@ SBD_DEVICE=/dev/vdc;/dev/vdd`
 func TestSbdDeviceParserWithSpaceAfterSemicolon(t *testing.T) {
 	sbdConfig := `SBD_DEVICE=/dev/vdc; /dev/vdd`
 
-	sbdDevices := getSbdDevices([]byte(sbdConfig))
+	sbdDevices := parseSbdConfig([]byte(sbdConfig), log.NewNopLogger()).devices()
 
 	assert.Len(t, sbdDevices, 2)
 	assert.Equal(t, "/dev/vdc", sbdDevices[0])
@@ -200,7 +206,7 @@ func TestSbdDeviceParserWithSpaceAfterSemicolon(t *testing.T) {
 func TestSbdDeviceParserWithSemicolon(t *testing.T) {
 	sbdConfig := `SBD_DEVICE=/dev/vdc;/dev/vdd;`
 
-	sbdDevices := getSbdDevices([]byte(sbdConfig))
+	sbdDevices := parseSbdConfig([]byte(sbdConfig), log.NewNopLogger()).devices()
 
 	assert.Len(t, sbdDevices, 2)
 	assert.Equal(t, "/dev/vdc", sbdDevices[0])
@@ -208,40 +214,124 @@ func TestSbdDeviceParserWithSemicolon(t *testing.T) {
 }
 
 func TestNewSbdCollector(t *testing.T) {
-	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/fake_sbdconfig", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Nil(t, err)
 }
 
 func TestNewSbdCollectorChecksSbdConfigExistence(t *testing.T) {
-	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/nonexistent", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/fake_sbd.sh", "../../test/nonexistent", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewSbdCollectorChecksSbdExistence(t *testing.T) {
-	_, err := NewCollector("../../test/nonexistent", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/nonexistent", "../../test/fake_sbdconfig", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/nonexistent' does not exist")
 }
 
 func TestNewSbdCollectorChecksSbdExecutableBits(t *testing.T) {
-	_, err := NewCollector("../../test/dummy", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	_, err := NewCollector("../../test/dummy", "../../test/fake_sbdconfig", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "'../../test/dummy' is not executable")
 }
 
 func TestSBDCollector(t *testing.T) {
-	collector, _ := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	collector, _ := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
+	collector.sysfsPath = "../../test/sysfs"
+	collector.procfsPath = "../../test/procfs"
+	collector.prober = stubDeviceProber()
 	assertcustom.Metrics(t, collector, "sbd.metrics")
 }
 
 func TestWatchdog(t *testing.T) {
-	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", false, log.NewNopLogger())
+	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
+	collector.sysfsPath = "../../test/sysfs"
+	collector.procfsPath = "../../test/procfs"
+	collector.prober = stubDeviceProber()
 
 	assert.Nil(t, err)
 	assertcustom.Metrics(t, collector, "sbd.metrics")
 }
+
+func TestSBDCollectorDiskless(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig_diskless", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
+	collector.sysfsPath = "../../test/sysfs"
+	collector.procfsPath = "../../test/procfs"
+	collector.prober = stubDeviceProber()
+
+	assert.Nil(t, err)
+	assertcustom.Metrics(t, collector, "sbd_diskless.metrics")
+}
+
+func TestSBDCollectorNotConfigured(t *testing.T) {
+	collector, err := NewCollector("../../test/fake_sbd_dump.sh", "../../test/fake_sbdconfig_diskless", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", collector.DirectExecutor{}, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	// like systemctl does when the sbd unit is not installed
+	collector.systemctlPath = "false"
+	collector.sysfsPath = "../../test/sysfs"
+	collector.procfsPath = "../../test/procfs"
+
+	expected := `# HELP ha_cluster_sbd_diskless Whether SBD runs in diskless mode, i.e. with no devices and the watchdog only
+# TYPE ha_cluster_sbd_diskless gauge
+ha_cluster_sbd_diskless 0
+`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "ha_cluster_sbd_diskless", "ha_cluster_sbd_timeout_checks")
+	assert.NoError(t, err)
+}
+
+// recordingExecutor runs the commands directly, keeping track of their arguments
+type recordingExecutor struct {
+	sync.Mutex
+	commands [][]string
+}
+
+func (e *recordingExecutor) Command(ctx context.Context, path string, args ...string) *exec.Cmd {
+	e.Lock()
+	e.commands = append(e.commands, args)
+	e.Unlock()
+	return collector.DirectExecutor{}.Command(ctx, path, args...)
+}
+
+func TestSBDCollectorDumpFailure(t *testing.T) {
+	executor := &recordingExecutor{}
+	// the fake sbd only succeeds for /dev/vdc, so the dump of /dev/vdd fails
+	collector, err := NewCollector("../../test/fake_sbd.sh", "../../test/fake_sbdconfig", "../../test/fake_cibadmin.sh", "../../test/fake_dmsetup.sh", "../../test/fake_systemctl.sh", executor, false, log.NewNopLogger())
+	assert.NoError(t, err)
+	collector.sysfsPath = "../../test/sysfs"
+	collector.procfsPath = "../../test/procfs"
+	collector.prober = stubDeviceProber()
+
+	expected := `# HELP ha_cluster_sbd_devices SBD devices; one line per device
+# TYPE ha_cluster_sbd_devices gauge
+ha_cluster_sbd_devices{device="/dev/vdc",status="healthy"} 1
+ha_cluster_sbd_devices{device="/dev/vdd",status="unhealthy"} 1
+`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "ha_cluster_sbd_devices")
+	assert.NoError(t, err)
+
+	// the failing device is neither listed nor probed
+	assert.Contains(t, executor.commands, []string{"-d", "/dev/vdc", "list"})
+	assert.NotContains(t, executor.commands, []string{"-d", "/dev/vdd", "list"})
+	_, _, probed := collector.prober.snapshot("/dev/vdd")
+	assert.False(t, probed)
+}
+
+func TestSbdCommands(t *testing.T) {
+	commands, err := Commands("/usr/sbin/sbd", "../../test/fake_sbdconfig", "/usr/sbin/cibadmin", "/usr/sbin/dmsetup", "/usr/bin/systemctl")
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"/usr/sbin/sbd", "-d", "/dev/vdc", "dump"},
+		{"/usr/sbin/sbd", "-d", "/dev/vdc", "list"},
+		{"/usr/sbin/sbd", "-d", "/dev/vdd", "dump"},
+		{"/usr/sbin/sbd", "-d", "/dev/vdd", "list"},
+		{"/usr/sbin/cibadmin", "--query", "--local"},
+		{"/usr/sbin/dmsetup", "status", "--target", "multipath"},
+		{"/usr/bin/systemctl", "is-enabled", "sbd.service"},
+	}, commands)
+}
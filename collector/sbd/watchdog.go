@@ -0,0 +1,119 @@
+package sbd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultWatchdogDevice = "/dev/watchdog"
+	// setting this as the watchdog device disables the watchdog altogether
+	noWatchdogDevice = "/dev/null"
+)
+
+// watchdog is the state of a watchdog device, as exposed in sysfs by the kernel
+type watchdog struct {
+	Name     string
+	Identity string
+	// either `active` or `inactive`; an active watchdog must be kept alive or it will reset the node
+	State string
+	// the numeric attributes, keyed by name, e.g. `timeout` or `nowayout`; not all the drivers expose all of them
+	Attributes map[string]float64
+}
+
+// the numeric watchdog attributes we read from sysfs
+var watchdogAttributes = []string{"timeout", "timeleft", "pretimeout", "status", "nowayout"}
+
+// watchdogName returns the name of a watchdog device in /sys/class/watchdog;
+// the legacy `/dev/watchdog` device is always an alias of the first watchdog, i.e. `watchdog0`
+func watchdogName(device string) string {
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	name := filepath.Base(device)
+	if name == "watchdog" {
+		return "watchdog0"
+	}
+	return name
+}
+
+// readWatchdog reads the state of a watchdog device from sysfs
+func readWatchdog(sysfsPath string, device string) (watchdog, error) {
+	w := watchdog{
+		Name:       watchdogName(device),
+		Attributes: make(map[string]float64),
+	}
+	dir := filepath.Join(sysfsPath, "class", "watchdog", w.Name)
+
+	identity, err := os.ReadFile(filepath.Join(dir, "identity"))
+	if err != nil {
+		return w, errors.Wrapf(err, "could not read the identity of watchdog %s", w.Name)
+	}
+	w.Identity = strings.TrimSpace(string(identity))
+
+	if state, err := os.ReadFile(filepath.Join(dir, "state")); err == nil {
+		w.State = strings.TrimSpace(string(state))
+	}
+
+	for _, attribute := range watchdogAttributes {
+		raw, err := os.ReadFile(filepath.Join(dir, attribute))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return w, errors.Wrapf(err, "could not read the %s of watchdog %s", attribute, w.Name)
+		}
+		// the status is a hexadecimal bitmask, e.g. `0x8000`, while the other attributes are decimal
+		value, err := strconv.ParseUint(strings.TrimSpace(string(raw)), 0, 64)
+		if err != nil {
+			return w, errors.Wrapf(err, "could not parse the %s of watchdog %s", attribute, w.Name)
+		}
+		w.Attributes[attribute] = float64(value)
+	}
+
+	return w, nil
+}
+
+// isWatchdogOpenBySbd checks whether any sbd process holds a file descriptor on the watchdog device;
+// sbd keeps it open for as long as it is feeding it, so this means that the watchdog is armed by sbd;
+// the file descriptors of the processes of other users can only be inspected by root, so it fails otherwise
+func isWatchdogOpenBySbd(procfsPath string, device string) (bool, error) {
+	paths := map[string]bool{device: true}
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		paths[resolved] = true
+	}
+
+	comms, err := filepath.Glob(filepath.Join(procfsPath, "[0-9]*", "comm"))
+	if err != nil {
+		return false, err
+	}
+	for _, commPath := range comms {
+		comm, err := os.ReadFile(commPath)
+		// processes may exit while we look at them
+		if err != nil || strings.TrimSpace(string(comm)) != "sbd" {
+			continue
+		}
+
+		fdDir := filepath.Join(filepath.Dir(commPath), "fd")
+		fds, err := os.ReadDir(fdDir)
+		// not being allowed to look doesn't mean that the watchdog is not open
+		if os.IsPermission(err) {
+			return false, err
+		}
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err == nil && paths[target] {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,64 @@
+package sbd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdogName(t *testing.T) {
+	assert.Equal(t, "watchdog0", watchdogName("/dev/watchdog"))
+	assert.Equal(t, "watchdog1", watchdogName("/dev/watchdog1"))
+}
+
+func TestReadWatchdog(t *testing.T) {
+	watchdog, err := readWatchdog("../../test/sysfs", "/dev/watchdog")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "watchdog0", watchdog.Name)
+	assert.Equal(t, "iTCO_wdt", watchdog.Identity)
+	assert.Equal(t, "active", watchdog.State)
+	// the pretimeout is not supported by this driver
+	assert.Equal(t, map[string]float64{
+		"timeout":  5,
+		"timeleft": 4,
+		"status":   0x8100,
+		"nowayout": 0,
+	}, watchdog.Attributes)
+}
+
+func TestReadWatchdogMissing(t *testing.T) {
+	_, err := readWatchdog("../../test/sysfs", "/dev/watchdog1")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not read the identity of watchdog watchdog1")
+}
+
+func TestIsWatchdogOpenBySbd(t *testing.T) {
+	open, err := isWatchdogOpenBySbd("../../test/procfs", "/dev/watchdog")
+	assert.NoError(t, err)
+	assert.True(t, open)
+
+	open, err = isWatchdogOpenBySbd("../../test/procfs", "/dev/watchdog1")
+	assert.NoError(t, err)
+	assert.False(t, open)
+}
+
+func TestIsWatchdogOpenBySbdPermissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root is allowed to read any file descriptor directory")
+	}
+	procfs := t.TempDir()
+	process := filepath.Join(procfs, "1234")
+	require.NoError(t, os.MkdirAll(filepath.Join(process, "fd"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(process, "comm"), []byte("sbd\n"), 0644))
+	// like the fd directory of a process of another user
+	require.NoError(t, os.Chmod(filepath.Join(process, "fd"), 0000))
+
+	_, err := isWatchdogOpenBySbd(procfs, "/dev/watchdog")
+
+	assert.True(t, os.IsPermission(err))
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the statuses a collector can have at startup
+const (
+	StatusEnabled     = "enabled"
+	StatusDisabled    = "disabled"
+	StatusUnavailable = "unavailable"
+)
+
+// StatusCollector reports the status of all the collectors, i.e. whether they were enabled, disabled by the user,
+// or enabled but unavailable, e.g. because the tools they depend on are not installed
+type StatusCollector struct {
+	statuses   map[string]string
+	statusDesc *prometheus.Desc
+}
+
+func NewStatusCollector(statuses map[string]string) *StatusCollector {
+	return &StatusCollector{
+		statuses,
+		prometheus.NewDesc(
+			prometheus.BuildFQName(NAMESPACE, "scrape", "collector_status"),
+			"The status of a collector; one of enabled, disabled or unavailable.",
+			[]string{"collector", "status"},
+			nil,
+		),
+	}
+}
+
+func (sc *StatusCollector) Collect(ch chan<- prometheus.Metric) {
+	names := make([]string, 0, len(sc.statuses))
+	for name := range sc.statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ch <- prometheus.MustNewConstMetric(sc.statusDesc, prometheus.GaugeValue, 1, name, sc.statuses[name])
+	}
+}
+
+func (sc *StatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.statusDesc
+}
@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusCollector(t *testing.T) {
+	SUT := NewStatusCollector(map[string]string{
+		"pacemaker": StatusEnabled,
+		"corosync":  StatusEnabled,
+		"sbd":       StatusUnavailable,
+		"drbd":      StatusDisabled,
+	})
+
+	metrics := `# HELP ha_cluster_scrape_collector_status The status of a collector; one of enabled, disabled or unavailable.
+# TYPE ha_cluster_scrape_collector_status gauge
+ha_cluster_scrape_collector_status{collector="corosync",status="enabled"} 1
+ha_cluster_scrape_collector_status{collector="drbd",status="disabled"} 1
+ha_cluster_scrape_collector_status{collector="pacemaker",status="enabled"} 1
+ha_cluster_scrape_collector_status{collector="sbd",status="unavailable"} 1
+`
+
+	err := testutil.CollectAndCompare(SUT, strings.NewReader(metrics))
+	assert.NoError(t, err)
+}
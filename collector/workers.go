@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultParallelism is how many tasks a collector runs at once, at most, when reading its data sources
+const DefaultParallelism = 4
+
+// Workers runs independent tasks concurrently, e.g. the commands a collector reads its data sources with,
+// so that a scrape takes as long as the slowest of them, rather than their sum.
+// At most `parallelism` tasks run at once, all sharing the deadline of the given context;
+// the tasks that haven't started by then are not run at all.
+type Workers struct {
+	ctx   context.Context
+	slots chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+	err   error
+}
+
+func NewWorkers(ctx context.Context, parallelism int) *Workers {
+	return &Workers{
+		ctx:   ctx,
+		slots: make(chan struct{}, parallelism),
+	}
+}
+
+// Go runs a task as soon as a worker is available
+func (w *Workers) Go(task func(ctx context.Context) error) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		select {
+		case w.slots <- struct{}{}:
+			defer func() { <-w.slots }()
+		case <-w.ctx.Done():
+		}
+		// a worker may also become available right at the deadline
+		if err := w.ctx.Err(); err != nil {
+			w.fail(err)
+			return
+		}
+
+		err := task(w.ctx)
+		if err != nil {
+			w.fail(err)
+		}
+	}()
+}
+
+// Wait waits for all the tasks to complete, and returns the first error any of them returned
+func (w *Workers) Wait() error {
+	w.wg.Wait()
+	return w.err
+}
+
+func (w *Workers) fail(err error) {
+	w.once.Do(func() {
+		w.err = err
+	})
+}
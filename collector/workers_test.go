@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkers(t *testing.T) {
+	var running, maxRunning, done int32
+	workers := NewWorkers(context.Background(), 2)
+	for i := 0; i < 6; i++ {
+		workers.Go(func(ctx context.Context) error {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&done, 1)
+			return nil
+		})
+	}
+
+	assert.NoError(t, workers.Wait())
+	assert.Equal(t, int32(6), done)
+	assert.Equal(t, int32(2), maxRunning)
+}
+
+func TestWorkersError(t *testing.T) {
+	workers := NewWorkers(context.Background(), DefaultParallelism)
+	workers.Go(func(ctx context.Context) error {
+		return nil
+	})
+	workers.Go(func(ctx context.Context) error {
+		return errors.New("test error")
+	})
+
+	assert.EqualError(t, workers.Wait(), "test error")
+}
+
+func TestWorkersDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var started int32
+	workers := NewWorkers(ctx, 1)
+	for i := 0; i < 3; i++ {
+		workers.Go(func(ctx context.Context) error {
+			atomic.AddInt32(&started, 1)
+			<-ctx.Done()
+			return nil
+		})
+	}
+
+	assert.ErrorIs(t, workers.Wait(), context.DeadlineExceeded)
+	assert.Equal(t, int32(1), started)
+}
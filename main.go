@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -34,6 +41,20 @@ const (
 	namespace = "ha_cluster_exporter"
 )
 
+// the names of the collectors, i.e. of the subsystems they collect metrics from, in registration order
+var collectorNames = []string{"pacemaker", "corosync", "sbd", "drbd"}
+
+// the collectors whose commands only require the privileges of the haclient group, which Pacemaker grants access
+// to the CIB; in the haclient executor mode, the commands of the others are run via sudo, including the Corosync ones,
+// since Corosync only grants IPC access to root and to the users and groups of its uidgid configuration
+var haclientCollectors = map[string]bool{"pacemaker": true}
+
+// how long the in-flight requests are waited for, when shutting down
+const shutdownTimeout = 10 * time.Second
+
+// the default timeout of each collector scrape, just below the default scrape timeout of Prometheus, i.e. 10s
+const defaultCollectorTimeout = "8s"
+
 var (
 	config *viper.Viper
 
@@ -44,15 +65,48 @@ var (
 	logLevel         *string
 	logFormat        *string
 
+	// collector toggles, keyed by collector name
+	collectorEnabled          map[string]*bool
+	collectorEnabledSetByUser map[string]*bool
+	collectorDisableDefaults  *bool
+	collectorTimeout          map[string]*time.Duration
+	collectorPollInterval     *time.Duration
+
 	// collector flags
 	haClusterCrmMonPath              *string
 	haClusterCibadminPath            *string
 	haClusterCorosyncCfgtoolpathPath *string
 	haClusterCorosyncQuorumtoolPath  *string
+	haClusterCorosyncCmapctlPath     *string
+	haClusterCorosyncIPC             *bool
+	haClusterCorosyncLogSource       *string
+	haClusterJournalctlPath          *string
 	haClusterSbdPath                 *string
 	haClusterSbdConfigPath           *string
+	haClusterDmsetupPath             *string
+	haClusterSystemctlPath           *string
 	haClusterDrbdsetupPath           *string
+	haClusterDrbdadmPath             *string
 	haClusterDrbdsplitbrainPath      *string
+	haClusterDrbdReactorConfigPath   *string
+	haClusterDrbdEvents              *bool
+	haClusterDrbdLegacyGauges        *bool
+	executorMode                     *string
+	sudoPath                         *string
+
+	// commands
+	command                      string
+	serveCommand                 *kingpin.CmdClause
+	drbdSplitBrainHookCommand    *kingpin.CmdClause
+	drbdSplitBrainHookResource   *string
+	drbdSplitBrainHookVolume     *string
+	drbdSplitBrainHookPeerNodeId *string
+	drbdSplitBrainClearCommand   *kingpin.CmdClause
+	drbdSplitBrainClearResource  *string
+	drbdSplitBrainClearVolume    *string
+	drbdSplitBrainClearAll       *bool
+	sudoersCommand               *kingpin.CmdClause
+	sudoersUser                  *string
 
 	// deprecated flags
 	enableTimestampsDeprecated *bool
@@ -91,6 +145,31 @@ func init() {
 		"[EXPERIMENTAL] Path to configuration file that can enable TLS or authentication.",
 	).PlaceHolder("/etc/" + namespace + ".web.yaml").Default(setConfigDefault("web.config.file", "/etc/"+namespace+".web.yaml")).String()
 
+	// collector toggles
+	collectorEnabled = make(map[string]*bool)
+	collectorEnabledSetByUser = make(map[string]*bool)
+	collectorTimeout = make(map[string]*time.Duration)
+	for _, name := range collectorNames {
+		var setByUser bool
+		collectorEnabled[name] = kingpin.Flag(
+			"collector."+name,
+			"enable the "+name+" collector",
+		).Default(setConfigDefault("collector."+name, "true")).IsSetByUser(&setByUser).Bool()
+		collectorEnabledSetByUser[name] = &setByUser
+		collectorTimeout[name] = kingpin.Flag(
+			"collector.timeout."+name,
+			"the time after which a "+name+" scrape is given up on, and the commands it runs are killed",
+		).PlaceHolder(defaultCollectorTimeout).Default(setConfigDefault("collector.timeout."+name, defaultCollectorTimeout)).Duration()
+	}
+	collectorPollInterval = kingpin.Flag(
+		"collector.poll-interval",
+		"poll the collectors in background at this interval, and serve their latest metrics, instead of scraping them on each request; 0 disables it",
+	).PlaceHolder("0s").Default(setConfigDefault("collector.poll-interval", "0s")).Duration()
+	collectorDisableDefaults = kingpin.Flag(
+		"collector.disable-defaults",
+		"disable all the collectors, but the ones explicitly enabled",
+	).PlaceHolder("false").Default(setConfigDefault("collector.disable-defaults", "false")).Bool()
+
 	// collector flags
 	haClusterCrmMonPath = kingpin.Flag(
 		"crm-mon-path",
@@ -108,6 +187,22 @@ func init() {
 		"corosync-quorumtool-path",
 		"path to corosync-quorumtool executable",
 	).PlaceHolder("/usr/sbin/corosync-quorumtool").Default(setConfigDefault("corosync-quorumtool-path", "/usr/sbin/corosync-quorumtool")).String()
+	haClusterCorosyncCmapctlPath = kingpin.Flag(
+		"corosync-cmapctl-path",
+		"path to corosync-cmapctl executable",
+	).PlaceHolder("/usr/sbin/corosync-cmapctl").Default(setConfigDefault("corosync-cmapctl-path", "/usr/sbin/corosync-cmapctl")).String()
+	haClusterCorosyncIPC = kingpin.Flag(
+		"corosync-ipc",
+		"[EXPERIMENTAL] query corosync via its IPC interface instead of its command line tools, which are still used as a fallback",
+	).PlaceHolder("false").Default(setConfigDefault("corosync-ipc", "false")).Bool()
+	haClusterCorosyncLogSource = kingpin.Flag(
+		"corosync-log-source",
+		"where to follow the corosync logs from, to count the totem issues they report: either 'journal' or the path to a log file; disabled when empty",
+	).PlaceHolder("/var/log/cluster/corosync.log").Default(setConfigDefault("corosync-log-source", "")).String()
+	haClusterJournalctlPath = kingpin.Flag(
+		"journalctl-path",
+		"path to journalctl executable, used to follow the corosync logs when their source is the journal",
+	).PlaceHolder("/usr/bin/journalctl").Default(setConfigDefault("journalctl-path", "/usr/bin/journalctl")).String()
 	haClusterSbdPath = kingpin.Flag(
 		"sbd-path",
 		"path to sbd executable",
@@ -116,14 +211,46 @@ func init() {
 		"sbd-config-path",
 		"path to sbd configuration",
 	).PlaceHolder("/etc/sysconfig/sbd").Default(setConfigDefault("sbd-config-path", "/etc/sysconfig/sbd")).String()
+	haClusterDmsetupPath = kingpin.Flag(
+		"dmsetup-path",
+		"path to dmsetup executable, used to check the paths of the SBD devices which are multipath maps",
+	).PlaceHolder("/usr/sbin/dmsetup").Default(setConfigDefault("dmsetup-path", "/usr/sbin/dmsetup")).String()
+	haClusterSystemctlPath = kingpin.Flag(
+		"systemctl-path",
+		"path to systemctl executable, used to check whether SBD is enabled when it has no device, and the state of the drbd-reactor promoter targets",
+	).PlaceHolder("/usr/bin/systemctl").Default(setConfigDefault("systemctl-path", "/usr/bin/systemctl")).String()
 	haClusterDrbdsetupPath = kingpin.Flag(
 		"drbdsetup-path",
 		"path to drbdsetup executable",
 	).PlaceHolder("/sbin/drbdsetup").Default(setConfigDefault("drbdsetup-path", "/sbin/drbdsetup")).String()
+	haClusterDrbdadmPath = kingpin.Flag(
+		"drbdadm-path",
+		"path to drbdadm executable",
+	).PlaceHolder("/sbin/drbdadm").Default(setConfigDefault("drbdadm-path", "/sbin/drbdadm")).String()
 	haClusterDrbdsplitbrainPath = kingpin.Flag(
 		"drbdsplitbrain-path",
 		"path to drbd splitbrain hooks temporary files",
 	).PlaceHolder("/var/run/drbd/splitbrain").Default(setConfigDefault("drbdsplitbrain-path", "/var/run/drbd/splitbrain")).String()
+	haClusterDrbdReactorConfigPath = kingpin.Flag(
+		"drbd-reactor-config-path",
+		"path to drbd-reactor configuration, to report the DRBD resources managed by its promoter plugin; ignored when it doesn't exist",
+	).PlaceHolder("/etc/drbd-reactor.toml").Default(setConfigDefault("drbd-reactor-config-path", "/etc/drbd-reactor.toml")).String()
+	haClusterDrbdEvents = kingpin.Flag(
+		"drbd-events",
+		"follow the DRBD events with a long running 'drbdsetup events2', to count the state transitions happening between scrapes",
+	).PlaceHolder("false").Default(setConfigDefault("drbd-events", "false")).Bool()
+	haClusterDrbdLegacyGauges = kingpin.Flag(
+		"drbd-legacy-gauges",
+		"also export the DRBD I/O statistics as the KiB gauges they used to be, before they were exported as counters",
+	).PlaceHolder("false").Default(setConfigDefault("drbd-legacy-gauges", "false")).Bool()
+	executorMode = kingpin.Flag(
+		"executor",
+		"how to run the commands of the collectors: 'direct', with the privileges of the exporter; 'sudo', via sudo -n; 'haclient', directly when the haclient group is enough, via sudo -n otherwise",
+	).PlaceHolder("direct").Default(setConfigDefault("executor", "direct")).Enum("direct", "sudo", "haclient")
+	sudoPath = kingpin.Flag(
+		"sudo-path",
+		"path to sudo executable, used by the 'sudo' and 'haclient' executors",
+	).PlaceHolder("/usr/bin/sudo").Default(setConfigDefault("sudo-path", "/usr/bin/sudo")).String()
 	enableTimestampsDeprecated = kingpin.Flag(
 		"enable-timestamps",
 		"[DEPRECATED] server-side metric timestamping is discouraged by Prometheus best-practices and should be avoided",
@@ -153,6 +280,53 @@ func init() {
 		"Output format of log messages. One of: [logfmt, json]",
 	).PlaceHolder("logfmt").Default(setConfigDefault("log.format", "logfmt")).String()
 
+	// commands
+	serveCommand = kingpin.Command(
+		"serve",
+		"Serve the metrics; this is the default command.",
+	).Default()
+	// DRBD calls its handlers with the details of the event in the environment
+	drbdSplitBrainHookCommand = kingpin.Command(
+		"drbd-split-brain-hook",
+		"Record a split brain detected by DRBD, for the drbd collector to report it; meant to be set as the DRBD 'split-brain' handler.",
+	)
+	drbdSplitBrainHookResource = drbdSplitBrainHookCommand.Flag(
+		"resource",
+		"the DRBD resource in split brain",
+	).Envar("DRBD_RESOURCE").Required().String()
+	drbdSplitBrainHookVolume = drbdSplitBrainHookCommand.Flag(
+		"volume",
+		"the DRBD volume in split brain",
+	).Envar("DRBD_VOLUME").Required().String()
+	drbdSplitBrainHookPeerNodeId = drbdSplitBrainHookCommand.Flag(
+		"peer-node-id",
+		"the id of the DRBD peer the split brain was detected with",
+	).Envar("DRBD_PEER_NODE_ID").String()
+	drbdSplitBrainClearCommand = kingpin.Command(
+		"drbd-split-brain-clear",
+		"Clear the split brains recorded for a DRBD resource, once they are resolved.",
+	)
+	drbdSplitBrainClearResource = drbdSplitBrainClearCommand.Arg(
+		"resource",
+		"the DRBD resource to clear the split brains of",
+	).String()
+	drbdSplitBrainClearVolume = drbdSplitBrainClearCommand.Arg(
+		"volume",
+		"the DRBD volume to clear the split brains of; all the volumes of the resource when omitted",
+	).String()
+	drbdSplitBrainClearAll = drbdSplitBrainClearCommand.Flag(
+		"all",
+		"clear the split brains of all the DRBD resources",
+	).Bool()
+	sudoersCommand = kingpin.Command(
+		"sudoers",
+		"Print a sudoers policy allowing the commands that the enabled collectors run via sudo, with the configured executor.",
+	)
+	sudoersUser = sudoersCommand.Flag(
+		"user",
+		"the user the exporter runs as",
+	).Required().String()
+
 	// detect unit testing and skip kingpin.Parse() in init.
 	// see: https://github.com/alecthomas/kingpin/issues/187
 	testing := (strings.HasSuffix(os.Args[0], ".test") ||
@@ -166,7 +340,7 @@ func init() {
 
 	var err error
 
-	kingpin.Parse()
+	command = kingpin.Parse()
 
 	// use deprecated log-level parameter if set
 	if *logLevelDeprecated != "info" {
@@ -197,71 +371,222 @@ func setConfigDefault(configName string, configDefault string) string {
 	return result
 }
 
-func registerCollectors(logger log.Logger) (collectors []prometheus.Collector, errors []error) {
-	pacemakerCollector, err := pacemaker.NewCollector(
-		*haClusterCrmMonPath,
-		*haClusterCibadminPath,
-		*enableTimestampsDeprecated,
-		logger,
-	)
-	if err != nil {
-		errors = append(errors, err)
-	} else {
-		collectors = append(collectors, pacemakerCollector)
+// isCollectorEnabled tells whether a collector is enabled; when the defaults are disabled,
+// only the collectors explicitly enabled, either via CLI flag or config file, are
+func isCollectorEnabled(name string) bool {
+	if *collectorDisableDefaults && !*collectorEnabledSetByUser[name] && !config.IsSet("collector."+name) {
+		return false
 	}
+	return *collectorEnabled[name]
+}
 
-	corosyncCollector, err := corosync.NewCollector(
-		*haClusterCorosyncCfgtoolpathPath,
-		*haClusterCorosyncQuorumtoolPath,
-		*enableTimestampsDeprecated,
-		logger,
-	)
-	if err != nil {
-		errors = append(errors, err)
-	} else {
-		collectors = append(collectors, corosyncCollector)
+func newCollector(name string, logger log.Logger) (prometheus.Collector, error) {
+	switch name {
+	case "pacemaker":
+		return pacemaker.NewCollector(
+			*haClusterCrmMonPath,
+			*haClusterCibadminPath,
+			newExecutor(name),
+			*enableTimestampsDeprecated,
+			logger,
+		)
+	case "corosync":
+		return corosync.NewCollector(
+			*haClusterCorosyncCfgtoolpathPath,
+			*haClusterCorosyncQuorumtoolPath,
+			*haClusterCorosyncCmapctlPath,
+			*haClusterJournalctlPath,
+			*haClusterCorosyncIPC,
+			*haClusterCorosyncLogSource,
+			newExecutor(name),
+			*enableTimestampsDeprecated,
+			logger,
+		)
+	case "sbd":
+		return sbd.NewCollector(
+			*haClusterSbdPath,
+			*haClusterSbdConfigPath,
+			*haClusterCibadminPath,
+			*haClusterDmsetupPath,
+			*haClusterSystemctlPath,
+			newExecutor(name),
+			*enableTimestampsDeprecated,
+			logger,
+		)
+	case "drbd":
+		return drbd.NewCollector(
+			*haClusterDrbdsetupPath,
+			*haClusterDrbdadmPath,
+			*haClusterDrbdsplitbrainPath,
+			*haClusterDrbdReactorConfigPath,
+			*haClusterSystemctlPath,
+			*haClusterDrbdEvents,
+			*haClusterDrbdLegacyGauges,
+			newExecutor(name),
+			*enableTimestampsDeprecated,
+			logger,
+		)
 	}
+	panic("unknown collector " + name)
+}
 
-	sbdCollector, err := sbd.NewCollector(
-		*haClusterSbdPath,
-		*haClusterSbdConfigPath,
-		*enableTimestampsDeprecated,
-		logger,
-	)
-	if err != nil {
-		errors = append(errors, err)
-	} else {
-		collectors = append(collectors, sbdCollector)
+// newExecutor returns the executor running the commands of a collector, according to the executor mode
+func newExecutor(name string) collector.Executor {
+	switch *executorMode {
+	case "sudo":
+		return collector.SudoExecutor{SudoPath: *sudoPath}
+	case "haclient":
+		if !haclientCollectors[name] {
+			return collector.SudoExecutor{SudoPath: *sudoPath}
+		}
 	}
+	return collector.DirectExecutor{}
+}
 
-	drbdCollector, err := drbd.NewCollector(
-		*haClusterDrbdsetupPath,
-		*haClusterDrbdsplitbrainPath,
-		*enableTimestampsDeprecated,
-		logger,
-	)
-	if err != nil {
-		errors = append(errors, err)
-	} else {
-		collectors = append(collectors, drbdCollector)
+// collectorCommands returns the commands a collector runs, with their arguments
+func collectorCommands(name string) ([][]string, error) {
+	switch name {
+	case "pacemaker":
+		return pacemaker.Commands(*haClusterCrmMonPath, *haClusterCibadminPath), nil
+	case "corosync":
+		return corosync.Commands(*haClusterCorosyncCfgtoolpathPath, *haClusterCorosyncQuorumtoolPath, *haClusterCorosyncCmapctlPath, *haClusterJournalctlPath, *haClusterCorosyncLogSource), nil
+	case "sbd":
+		return sbd.Commands(*haClusterSbdPath, *haClusterSbdConfigPath, *haClusterCibadminPath, *haClusterDmsetupPath, *haClusterSystemctlPath)
+	case "drbd":
+		return drbd.Commands(*haClusterDrbdsetupPath, *haClusterDrbdadmPath, *haClusterDrbdReactorConfigPath, *haClusterSystemctlPath, *haClusterDrbdEvents)
+	}
+	panic("unknown collector " + name)
+}
+
+// registerCollectors creates and registers the enabled collectors; the ones that are polled stop once the context is done
+func registerCollectors(ctx context.Context, logger log.Logger) (collectors []prometheus.Collector, errors []error) {
+	statuses := make(map[string]string)
+	for _, name := range collectorNames {
+		if !isCollectorEnabled(name) {
+			level.Info(logger).Log("msg", name+" collector disabled.")
+			statuses[name] = collector.StatusDisabled
+			continue
+		}
+		c, err := newCollector(name, logger)
+		if err != nil {
+			errors = append(errors, err)
+			statuses[name] = collector.StatusUnavailable
+			continue
+		}
+		collectors = append(collectors, c)
+		statuses[name] = collector.StatusEnabled
 	}
 
 	for i, c := range collectors {
 		if c, ok := c.(collector.InstrumentableCollector); ok {
-			collectors[i] = collector.NewInstrumentedCollector(c, logger)
+			instrumented := collector.NewInstrumentedCollector(c, *collectorTimeout[c.GetSubsystem()], logger)
+			if *collectorPollInterval > 0 {
+				instrumented.Poll(ctx, *collectorPollInterval)
+			}
+			collectors[i] = instrumented
 		}
 	}
 
 	prometheus.MustRegister(collectors...)
+	prometheus.MustRegister(collector.NewStatusCollector(statuses))
 
 	return collectors, errors
 }
 
+// runDrbdSplitBrainHook records a DRBD split brain in the directory the drbd collector looks at
+func runDrbdSplitBrainHook(logger log.Logger) int {
+	err := drbd.WriteSplitBrainMarker(*haClusterDrbdsplitbrainPath, *drbdSplitBrainHookResource, *drbdSplitBrainHookVolume, *drbdSplitBrainHookPeerNodeId, time.Now())
+	if err != nil {
+		level.Error(logger).Log("msg", "Could not record the DRBD split brain", "err", err)
+		return 1
+	}
+	level.Info(logger).Log("msg", "DRBD split brain recorded", "resource", *drbdSplitBrainHookResource, "volume", *drbdSplitBrainHookVolume)
+	return 0
+}
+
+// runDrbdSplitBrainClear clears the DRBD split brains recorded by the hook, printing the ones that were cleared
+func runDrbdSplitBrainClear() int {
+	if *drbdSplitBrainClearResource == "" && !*drbdSplitBrainClearAll {
+		fmt.Printf("%s: error: either a resource or --all is required, try --help\n", namespace)
+		return 1
+	}
+	if *drbdSplitBrainClearResource != "" && *drbdSplitBrainClearAll {
+		fmt.Printf("%s: error: a resource and --all are mutually exclusive, try --help\n", namespace)
+		return 1
+	}
+
+	cleared, err := drbd.ClearSplitBrainMarkers(*haClusterDrbdsplitbrainPath, *drbdSplitBrainClearResource, *drbdSplitBrainClearVolume)
+	for _, marker := range cleared {
+		fmt.Printf("cleared split brain of resource %s, volume %s, detected at %s\n", marker.Resource, marker.Volume, marker.DetectedAt.Format(time.RFC3339))
+	}
+	if err != nil {
+		fmt.Printf("%s: error: %s\n", namespace, err)
+		return 1
+	}
+	if len(cleared) == 0 {
+		fmt.Println("no split brain to clear")
+	}
+	return 0
+}
+
+// isHaclientMember tells whether the exporter runs with the privileges of the haclient group
+func isHaclientMember() bool {
+	group, err := user.LookupGroup("haclient")
+	if err != nil {
+		return false
+	}
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		return false
+	}
+	groups, _ := os.Getgroups()
+	for _, g := range append(groups, os.Getegid()) {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+func runSudoers() int {
+	var commands [][]string
+	for _, name := range collectorNames {
+		if !isCollectorEnabled(name) {
+			continue
+		}
+		if _, ok := newExecutor(name).(collector.SudoExecutor); !ok {
+			continue
+		}
+		subsystemCommands, err := collectorCommands(name)
+		if err != nil {
+			fmt.Printf("%s: error: could not list the commands of the %s collector: %s\n", namespace, name, err)
+			return 1
+		}
+		commands = append(commands, subsystemCommands...)
+	}
+	if len(commands) == 0 {
+		fmt.Printf("%s: error: no command runs via sudo with the '%s' executor, try --executor=sudo\n", namespace, *executorMode)
+		return 1
+	}
+
+	fmt.Print(collector.Sudoers(*sudoersUser, commands))
+	return 0
+}
+
 func main() {
 	var err error
 
 	logger := promlog.New(promlogConfig)
 
+	switch command {
+	case drbdSplitBrainHookCommand.FullCommand():
+		os.Exit(runDrbdSplitBrainHook(logger))
+	case drbdSplitBrainClearCommand.FullCommand():
+		os.Exit(runDrbdSplitBrainClear())
+	case sudoersCommand.FullCommand():
+		os.Exit(runSudoers())
+	}
+
 	level.Info(logger).Log("msg", fmt.Sprintf("Starting %s %s", namespace, version.Info()))
 	level.Info(logger).Log("msg", fmt.Sprintf("Build context %s", version.BuildContext()))
 
@@ -274,8 +599,16 @@ func main() {
 		level.Info(logger).Log("msg", "Using config file: "+config.ConfigFileUsed())
 	}
 
+	if *executorMode == "haclient" && !isHaclientMember() {
+		level.Warn(logger).Log("msg", "The exporter is not a member of the haclient group, so the pacemaker commands will likely fail")
+	}
+
+	// the collectors are polled, if they are, until the exporter shuts down
+	shutdown, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// register collectors
-	collectors, errors := registerCollectors(logger)
+	collectors, errors := registerCollectors(shutdown, logger)
 	for _, err = range errors {
 		level.Warn(logger).Log("msg", "Registration failure", "err", err)
 	}
@@ -342,6 +675,17 @@ func main() {
 		}(),
 	}
 
+	// on SIGINT or SIGTERM, the server stops accepting requests, and the collectors are closed once the in-flight ones are served
+	go func() {
+		<-shutdown.Done()
+		level.Info(logger).Log("msg", "Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := serveAddress.Shutdown(ctx); err != nil {
+			level.Warn(logger).Log("msg", "Could not shut down the HTTP server gracefully", "err", err)
+		}
+	}()
+
 	var listen error
 	_, err = os.Stat(*webConfig)
 
@@ -355,8 +699,20 @@ func main() {
 		listen = web.ListenAndServe(serveAddress, toolkitFlags, logger)
 	}
 
-	if err := listen; err != nil {
+	if err := listen; err != nil && err != http.ErrServerClosed {
 		level.Error(logger).Log("msg", "Error starting HTTP server", "err", err)
 		os.Exit(1)
 	}
+	closeCollectors(collectors, logger)
+}
+
+// closeCollectors stops the background work of the collectors, e.g. following the logs or the events of a component
+func closeCollectors(collectors []prometheus.Collector, logger log.Logger) {
+	for _, c := range collectors {
+		if c, ok := c.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				level.Warn(logger).Log("msg", "Could not close collector", "err", err)
+			}
+		}
+	}
 }
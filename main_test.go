@@ -18,7 +18,10 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ClusterLabs/ha_cluster_exporter/collector"
 )
 
 func TestRegisterCollectors(t *testing.T) {
@@ -26,17 +29,22 @@ func TestRegisterCollectors(t *testing.T) {
 	*haClusterCibadminPath = "test/fake_cibadmin.sh"
 	*haClusterCorosyncCfgtoolpathPath = "test/fake_corosync-cfgtool.sh"
 	*haClusterCorosyncQuorumtoolPath = "test/fake_corosync-quorumtool.sh"
+	*haClusterCorosyncCmapctlPath = "test/fake_corosync-cmapctl.sh"
 	*haClusterSbdPath = "test/fake_sbd.sh"
 	*haClusterSbdConfigPath = "test/fake_sbdconfig"
 	*haClusterDrbdsetupPath = "test/fake_drbdsetup.sh"
+	*haClusterDrbdadmPath = "test/fake_drbdadm.sh"
 	*haClusterDrbdsplitbrainPath = "test/fake_drbdsplitbrain"
+	for _, name := range collectorNames {
+		*collectorEnabled[name] = true
+	}
 
 	t.Run("success", func(t *testing.T) {
 		wantCollectors := 4
 		wantErrors := 0
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
-		collectors, errors := registerCollectors(log.NewNopLogger())
+		collectors, errors := registerCollectors(context.Background(), log.NewNopLogger())
 		assert.Len(t, collectors, wantCollectors)
 		assert.Len(t, errors, wantErrors)
 	})
@@ -47,7 +55,7 @@ func TestRegisterCollectors(t *testing.T) {
 		wantErrors := 1
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
-		collectors, errors := registerCollectors(log.NewNopLogger())
+		collectors, errors := registerCollectors(context.Background(), log.NewNopLogger())
 		assert.Len(t, collectors, wantCollectors)
 		assert.Len(t, errors, wantErrors)
 	})
@@ -58,7 +66,7 @@ func TestRegisterCollectors(t *testing.T) {
 		wantErrors := 2
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
-		collectors, errors := registerCollectors(log.NewNopLogger())
+		collectors, errors := registerCollectors(context.Background(), log.NewNopLogger())
 		assert.Len(t, collectors, wantCollectors)
 		assert.Len(t, errors, wantErrors)
 	})
@@ -69,7 +77,7 @@ func TestRegisterCollectors(t *testing.T) {
 		wantErrors := 3
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
-		collectors, errors := registerCollectors(log.NewNopLogger())
+		collectors, errors := registerCollectors(context.Background(), log.NewNopLogger())
 		assert.Len(t, collectors, wantCollectors)
 		assert.Len(t, errors, wantErrors)
 	})
@@ -80,12 +88,72 @@ func TestRegisterCollectors(t *testing.T) {
 		wantErrors := 4
 		prometheus.DefaultRegisterer = prometheus.NewRegistry()
 		prometheus.DefaultGatherer = prometheus.NewRegistry()
-		collectors, errors := registerCollectors(log.NewNopLogger())
+		collectors, errors := registerCollectors(context.Background(), log.NewNopLogger())
 		assert.Len(t, collectors, wantCollectors)
 		assert.Len(t, errors, wantErrors)
 	})
 }
 
+func TestRegisterCollectorsWithDisabledCollectors(t *testing.T) {
+	*haClusterCrmMonPath = "test/fake_crm_mon.sh"
+	*haClusterCibadminPath = "test/fake_cibadmin.sh"
+	*haClusterCorosyncCfgtoolpathPath = "test/fake_corosync-cfgtool.sh"
+	*haClusterCorosyncQuorumtoolPath = "test/fake_corosync-quorumtool.sh"
+	*haClusterCorosyncCmapctlPath = "test/fake_corosync-cmapctl.sh"
+	*haClusterSbdPath = "test/fake_sbd.sh"
+	*haClusterSbdConfigPath = "test/fake_sbdconfig"
+	*haClusterDrbdsetupPath = "test/fake_drbdsetup.sh"
+	*haClusterDrbdadmPath = "test/fake_drbdadm.sh"
+	*haClusterDrbdsplitbrainPath = "test/fake_drbdsplitbrain"
+	for _, name := range collectorNames {
+		*collectorEnabled[name] = true
+	}
+	defer func() {
+		*collectorEnabled["sbd"] = true
+		*collectorEnabledSetByUser["drbd"] = false
+		*collectorDisableDefaults = false
+	}()
+
+	t.Run("1 disabled", func(t *testing.T) {
+		*collectorEnabled["sbd"] = false
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+		prometheus.DefaultGatherer = prometheus.NewRegistry()
+		collectors, errors := registerCollectors(context.Background(), log.NewNopLogger())
+		assert.Len(t, collectors, 3)
+		assert.Len(t, errors, 0)
+	})
+
+	t.Run("defaults disabled", func(t *testing.T) {
+		// the collectors enabled in a config file, if any, count as explicitly enabled
+		defer func(c *viper.Viper) { config = c }(config)
+		config = viper.New()
+		*collectorDisableDefaults = true
+		*collectorEnabledSetByUser["drbd"] = true
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+		prometheus.DefaultGatherer = prometheus.NewRegistry()
+		collectors, errors := registerCollectors(context.Background(), log.NewNopLogger())
+		assert.Len(t, collectors, 1)
+		assert.Len(t, errors, 0)
+	})
+}
+
+func TestNewExecutor(t *testing.T) {
+	*sudoPath = "/usr/bin/sudo"
+	defer func() { *executorMode = "direct" }()
+
+	*executorMode = "direct"
+	assert.Equal(t, collector.DirectExecutor{}, newExecutor("sbd"))
+
+	*executorMode = "sudo"
+	assert.Equal(t, collector.SudoExecutor{SudoPath: "/usr/bin/sudo"}, newExecutor("pacemaker"))
+
+	*executorMode = "haclient"
+	assert.Equal(t, collector.DirectExecutor{}, newExecutor("pacemaker"))
+	assert.Equal(t, collector.SudoExecutor{SudoPath: "/usr/bin/sudo"}, newExecutor("corosync"))
+	assert.Equal(t, collector.SudoExecutor{SudoPath: "/usr/bin/sudo"}, newExecutor("sbd"))
+	assert.Equal(t, collector.SudoExecutor{SudoPath: "/usr/bin/sudo"}, newExecutor("drbd"))
+}
+
 //// Kudos for the build/run tests to https://github.com/prometheus/mysqld_exporter
 // TestBin builds, runs and tests binary.
 
@@ -138,6 +206,7 @@ func TestBin(t *testing.T) {
 
 	tests := []func(*testing.T, bin){
 		testLandingPage,
+		testShutdown,
 	}
 
 	portStart := 56000
@@ -205,6 +274,38 @@ func testLandingPage(t *testing.T, data bin) {
 	}
 }
 
+func testShutdown(t *testing.T, data bin) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Run exporter, polling the collectors in background.
+	cmd := exec.CommandContext(
+		ctx,
+		data.path,
+		"--web.listen-address", fmt.Sprintf(":%d", data.port),
+		"--collector.poll-interval=1s",
+		"--crm-mon-path=test/fake_crm_mon.sh", // needed to register at least one collector
+		"--cibadmin-path=test/fake_cibadmin.sh",
+	)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := waitForBody(fmt.Sprintf("http://127.0.0.1:%d", data.port)); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		t.Fatal(err)
+	}
+
+	// On SIGTERM, it exits cleanly once the server and the collectors are stopped.
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("the exporter did not shut down cleanly: %s", err)
+	}
+}
+
 // waitForBody is a helper function which makes http calls until http server is up
 // and then returns body of the successful call.
 func waitForBody(urlToGet string) (body []byte, err error) {
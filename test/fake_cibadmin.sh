@@ -11,6 +11,7 @@ cat <<EOF
         <nvpair id="cib-bootstrap-options-cluster-name" name="cluster-name" value="hana_cluster"/>
         <nvpair name="stonith-enabled" value="true" id="cib-bootstrap-options-stonith-enabled"/>
         <nvpair name="placement-strategy" value="balanced" id="cib-bootstrap-options-placement-strategy"/>
+        <nvpair name="stonith-timeout" value="150s" id="cib-bootstrap-options-stonith-timeout"/>
       </cluster_property_set>
     </crm_config>
     <nodes>
@@ -0,0 +1,126 @@
+#!/usr/bin/env bash
+
+if [[ "$1" == "-m" && "$2" == "stats" ]]; then
+  cat <<EOT
+stats.ipcs.global.active (u64) = 2
+stats.ipcs.global.closed (u64) = 1002
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.dispatched (u64) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.flow_control (u32) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.flow_control_count (u64) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.invalid_request (u64) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.overload (u64) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.procname (str) = pacemakerd
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.queued (u32) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.queueing (i32) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.recv_retries (u64) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.requests (u64) = 20
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.responses (u64) = 20
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.send_retries (u64) = 0
+stats.ipcs.service0.2037.0x55b4a4c0f2e0.sent (u32) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.dispatched (u64) = 15
+stats.ipcs.service2.2042.0x55b4a4c13a90.flow_control (u32) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.flow_control_count (u64) = 1
+stats.ipcs.service2.2042.0x55b4a4c13a90.invalid_request (u64) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.overload (u64) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.procname (str) = pacemaker-based
+stats.ipcs.service2.2042.0x55b4a4c13a90.queued (u32) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.queueing (i32) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.recv_retries (u64) = 0
+stats.ipcs.service2.2042.0x55b4a4c13a90.requests (u64) = 9
+stats.ipcs.service2.2042.0x55b4a4c13a90.responses (u64) = 9
+stats.ipcs.service2.2042.0x55b4a4c13a90.send_retries (u64) = 2
+stats.ipcs.service2.2042.0x55b4a4c13a90.sent (u32) = 15
+stats.pg.msg_queue_avail (u32) = 0
+stats.pg.msg_reserved (u32) = 1
+stats.srp.avg_backlog_calc (u32) = 0
+stats.srp.avg_token_workload (u32) = 0
+stats.srp.commit_entered (u64) = 2
+stats.srp.commit_token_lost (u64) = 0
+stats.srp.consensus_timeouts (u64) = 0
+stats.srp.continuous_gather (u32) = 0
+stats.srp.continuous_sendmsg_failures (u32) = 0
+stats.srp.firewall_enabled_or_nic_failure (u8) = 0
+stats.srp.gather_entered (u64) = 2
+stats.srp.gather_token_lost (u64) = 0
+stats.srp.mcast_retx (u64) = 3
+stats.srp.mcast_rx (u64) = 4138
+stats.srp.mcast_tx (u64) = 4096
+stats.srp.memb_commit_token_rx (u64) = 4
+stats.srp.memb_commit_token_tx (u64) = 4
+stats.srp.memb_join_rx (u64) = 5
+stats.srp.memb_join_tx (u64) = 3
+stats.srp.memb_merge_detect_rx (u64) = 1384
+stats.srp.memb_merge_detect_tx (u64) = 1384
+stats.srp.mtt_rx_token (u32) = 154
+stats.srp.operational_entered (u64) = 2
+stats.srp.operational_token_lost (u64) = 0
+stats.srp.orf_token_rx (u64) = 2782
+stats.srp.orf_token_tx (u64) = 1
+stats.srp.recovery_entered (u64) = 2
+stats.srp.recovery_token_lost (u64) = 0
+stats.srp.rx_msg_dropped (u64) = 0
+stats.srp.time_since_token_last_received (u64) = 129
+stats.srp.token_hold_cancel_rx (u64) = 0
+stats.srp.token_hold_cancel_tx (u64) = 0
+EOT
+  exit 0
+fi
+
+cat <<EOT
+config.totemconfig_reload_in_progress (u8) = 0
+internal_configuration.service.0.name (str) = corosync_cmap
+internal_configuration.service.0.ver (u32) = 0
+internal_configuration.service.1.name (str) = corosync_cfg
+internal_configuration.service.1.ver (u32) = 0
+internal_configuration.service.2.name (str) = corosync_cpg
+internal_configuration.service.2.ver (u32) = 0
+internal_configuration.service.3.name (str) = corosync_quorum
+internal_configuration.service.3.ver (u32) = 0
+internal_configuration.service.4.name (str) = corosync_pload
+internal_configuration.service.4.ver (u32) = 0
+internal_configuration.service.5.name (str) = corosync_votequorum
+internal_configuration.service.5.ver (u32) = 0
+logging.fileline (str) = off
+logging.logfile (str) = /var/log/cluster/corosync.log
+logging.timestamp (str) = on
+logging.to_logfile (str) = no
+logging.to_syslog (str) = yes
+nodelist.local_node_pos (u32) = 0
+nodelist.node.0.name (str) = stefanotorresi-hana01
+nodelist.node.0.nodeid (u32) = 1084783375
+nodelist.node.0.ring0_addr (str) = 10.0.0.1
+nodelist.node.0.ring1_addr (str) = 172.16.0.1
+nodelist.node.1.nodeid (u32) = 1084783376
+nodelist.node.1.ring0_addr (str) = 10.0.0.2
+nodelist.node.1.ring1_addr (str) = 172.16.0.2
+quorum.expected_votes (u32) = 2
+quorum.provider (str) = corosync_votequorum
+quorum.two_node (u8) = 1
+runtime.blackbox.dump_flight_data (str) = no
+runtime.blackbox.dump_state (str) = no
+runtime.config.totem.consensus (u32) = 6000
+runtime.config.totem.token (u32) = 5000
+runtime.members.1084783375.config_version (u64) = 0
+runtime.members.1084783375.ip (str) = r(0) ip(10.0.0.1) r(1) ip(172.16.0.1) 
+runtime.members.1084783375.join_count (u32) = 1
+runtime.members.1084783375.status (str) = joined
+runtime.members.1084783376.config_version (u64) = 0
+runtime.members.1084783376.ip (str) = r(0) ip(10.0.0.2) r(1) ip(172.16.0.2) 
+runtime.members.1084783376.join_count (u32) = 3
+runtime.members.1084783376.status (str) = joined
+runtime.votequorum.ev_barrier (u32) = 2
+runtime.votequorum.highest_node_id (u32) = 1084783376
+runtime.votequorum.lowest_node_id (u32) = 1084783375
+runtime.votequorum.this_node_id (u32) = 1084783375
+runtime.votequorum.two_node (u8) = 1
+totem.cluster_name (str) = hacluster
+totem.consensus (u32) = 6000
+totem.crypto_cipher (str) = none
+totem.crypto_hash (str) = none
+totem.max_messages (u32) = 20
+totem.rrp_mode (str) = passive
+totem.secauth (str) = off
+totem.token (u32) = 5000
+totem.transport (str) = udpu
+totem.version (u32) = 2
+EOT
@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+
+if [[ "$*" != "status --target multipath" ]]; then
+  exit 1
+fi
+
+cat <<EOT
+mpatha: 0 2097152 multipath 2 0 0 0 2 1 A 0 1 2 8:0 A 0 0 1 E 0 1 2 8:16 F 1 0 1
+mpathb: 0 2097152 multipath 2 0 0 0 1 1 A 0 1 0 8:32 A 0
+EOT
@@ -0,0 +1,65 @@
+#!/usr/bin/env bash
+
+if [[ "$1" != "dump-xml" ]]; then
+  exit 1
+fi
+
+cat <<XML
+<config file="/etc/drbd.conf">
+   <common>
+      <section name="net">
+         <option name="protocol" value="C"/>
+      </section>
+   </common>
+   <resource name="1-single-0" conf-file-line="/etc/drbd.d/1-single-0.res:1">
+      <host name="SLE15-sp1-gm-drbd1145296-node1">
+         <node-id>1</node-id>
+         <volume vnr="0">
+            <device minor="2">/dev/drbd2</device>
+            <disk>/dev/vdb1</disk>
+            <meta-disk>internal</meta-disk>
+         </volume>
+         <address family="ipv4" port="7790">192.168.124.10</address>
+      </host>
+      <host name="SLE15-sp1-gm-drbd1145296-node2">
+         <node-id>2</node-id>
+         <volume vnr="0">
+            <device minor="2">/dev/drbd2</device>
+            <disk>/dev/vdb1</disk>
+            <meta-disk>internal</meta-disk>
+         </volume>
+         <address family="ipv4" port="7790">192.168.124.11</address>
+      </host>
+      <section name="net">
+         <option name="fencing" value="resource-only"/>
+      </section>
+      <section name="options">
+         <option name="quorum" value="majority"/>
+         <option name="on-no-quorum" value="io-error"/>
+      </section>
+   </resource>
+   <resource name="1-single-1" conf-file-line="/etc/drbd.d/1-single-1.res:1">
+      <host name="SLE15-sp1-gm-drbd1145296-node1">
+         <node-id>1</node-id>
+         <volume vnr="0">
+            <device minor="3">/dev/drbd3</device>
+            <disk>/dev/vdb2</disk>
+            <meta-disk>internal</meta-disk>
+         </volume>
+         <address family="ipv6" port="7791">fd00::10</address>
+      </host>
+      <host name="SLE15-sp1-gm-drbd1145296-node2">
+         <node-id>2</node-id>
+         <volume vnr="0">
+            <device minor="3">/dev/drbd3</device>
+            <disk>/dev/vdb2</disk>
+            <meta-disk>internal</meta-disk>
+         </volume>
+         <address family="ipv6" port="7791">fd00::11</address>
+      </host>
+      <section name="net">
+         <option name="protocol" value="A"/>
+      </section>
+   </resource>
+</config>
+XML
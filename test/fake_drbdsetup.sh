@@ -1,5 +1,23 @@
 #!/usr/bin/env bash
 
+if [[ "$1" == "events2" ]]; then
+  cat <<EOF
+2020-01-15T10:12:05.123456+01:00 exists resource name:1-single-0 role:Secondary suspended:no write-ordering:flush
+2020-01-15T10:12:05.123456+01:00 exists connection name:1-single-0 peer-node-id:1 conn-name:node1 connection:Connected role:Primary
+2020-01-15T10:12:05.123456+01:00 exists device name:1-single-0 volume:0 minor:2 disk:UpToDate client:no quorum:yes
+2020-01-15T10:12:05.123456+01:00 exists peer-device name:1-single-0 peer-node-id:1 conn-name:node1 volume:0 replication:Established peer-disk:UpToDate peer-client:no resync-suspended:no
+2020-01-15T10:12:05.123456+01:00 exists -
+2020-01-15T10:13:42.654321+01:00 change connection name:1-single-0 peer-node-id:1 conn-name:node1 connection:BrokenPipe role:Unknown
+2020-01-15T10:13:42.654321+01:00 change connection name:1-single-0 peer-node-id:1 conn-name:node1 connection:Connecting
+2020-01-15T10:13:43.000000+01:00 change device name:1-single-0 volume:0 minor:2 disk:Outdated
+2020-01-15T10:13:44.000000+01:00 change connection name:1-single-0 peer-node-id:1 conn-name:node1 connection:Connected role:Primary
+2020-01-15T10:13:44.000000+01:00 call helper name:1-single-0 peer-node-id:1 conn-name:node1 volume:0 helper:split-brain
+2020-01-15T10:13:45.000000+01:00 change resource name:1-single-0 role:Primary
+2020-01-15T10:13:46.000000+01:00 change device name:1-single-0 volume:0 minor:2 disk:UpToDate
+EOF
+  exit 0
+fi
+
 cat <<EOF
 [
   {
@@ -21,7 +39,8 @@ cat <<EOF
         "al-writes": 123,
         "bm-writes": 321,
         "upper-pending": 1,
-        "lower-pending": 2
+        "lower-pending": 2,
+        "al-suspended": false
       }
     ],
     "connections": [
@@ -57,7 +76,7 @@ cat <<EOF
     "name": "1-single-1",
     "node-id": 2,
     "role": "Secondary",
-    "suspended": false,
+    "suspended": true,
     "write-ordering": "flush",
     "devices": [
       {
@@ -72,7 +91,8 @@ cat <<EOF
         "al-writes": 123,
         "bm-writes": 321,
         "upper-pending": 1,
-        "lower-pending": 2
+        "lower-pending": 2,
+        "al-suspended": false
       }
     ],
     "connections": [
@@ -80,25 +100,34 @@ cat <<EOF
         "peer-node-id": 1,
         "name": "SLE15-sp1-gm-drbd1145296-node1",
         "connection-state": "Connected",
-        "congested": false,
+        "congested": true,
         "peer-role": "Primary",
         "ap-in-flight": 0,
         "rs-in-flight": 0,
         "peer_devices": [
           {
             "volume": 0,
-            "replication-state": "Established",
+            "replication-state": "SyncSource",
             "peer-disk-state": "UpToDate",
             "peer-client": false,
             "resync-suspended": "no",
             "received": 456,
             "sent": 654,
-            "out-of-sync": 0,
+            "out-of-sync": 20480,
             "pending": 3,
             "unacked": 4,
-            "has-sync-details": false,
+            "has-sync-details": true,
             "has-online-verify-details": false,
-            "percent-in-sync": 100
+            "percent-in-sync": 80,
+            "rs-total": 102400,
+            "rs-dt-start-ms": 12000,
+            "rs-paused-ms": 0,
+            "rs-dt0-ms": 3000,
+            "rs-db0-sectors": 61440,
+            "rs-dt1-ms": 12000,
+            "rs-db1-sectors": 163840,
+            "rs-failed": 0,
+            "rs-same-csum": 0
           }
         ]
       }
@@ -0,0 +1,9 @@
+#!/usr/bin/env bash
+
+if [[ "$*" != "--follow --lines=0 --output=export --unit=corosync.service" ]]; then
+  exit 1
+fi
+
+# like journalctl --follow, print the new entries, then wait for more until killed
+printf '__REALTIME_TIMESTAMP=1571403000000000\n_SYSTEMD_UNIT=corosync.service\nMESSAGE=[TOTEM ] A processor failed, forming new configuration.\n\n'
+exec sleep infinity
@@ -0,0 +1,5 @@
+#!/usr/bin/env bash
+
+# like journalctl --follow, but printing something that is not in the export format, then waiting until killed
+printf 'not an export entry\n'
+exec sleep infinity
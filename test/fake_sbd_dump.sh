@@ -1,5 +1,13 @@
 #!/usr/bin/env bash
 
+if [[ "$3" == "list" ]]; then
+  cat <<EOT
+0	node01	clear
+1	node03	reset	node01
+EOT
+  exit 0
+fi
+
 cat <<EOF
 ==Dumping header on disk /dev/vdc
 Header version     : 2.1
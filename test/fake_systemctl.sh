@@ -0,0 +1,16 @@
+#!/usr/bin/env bash
+
+if [[ "$1" == "is-enabled" ]]; then
+  echo "enabled"
+  exit 0
+fi
+
+if [[ "$1" != "is-active" ]]; then
+  exit 1
+fi
+
+# like systemctl, exit with an error when any of the units is not active
+for unit in "${@:2}"; do
+  echo "inactive"
+done
+exit 3
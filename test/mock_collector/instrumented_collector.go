@@ -5,6 +5,7 @@
 package mock_collector
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -47,17 +48,17 @@ func (mr *MockInstrumentableCollectorMockRecorder) Collect(arg0 interface{}) *go
 }
 
 // CollectWithError mocks base method.
-func (m *MockInstrumentableCollector) CollectWithError(arg0 chan<- prometheus.Metric) error {
+func (m *MockInstrumentableCollector) CollectWithError(arg0 context.Context, arg1 chan<- prometheus.Metric) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CollectWithError", arg0)
+	ret := m.ctrl.Call(m, "CollectWithError", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CollectWithError indicates an expected call of CollectWithError.
-func (mr *MockInstrumentableCollectorMockRecorder) CollectWithError(arg0 interface{}) *gomock.Call {
+func (mr *MockInstrumentableCollectorMockRecorder) CollectWithError(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectWithError", reflect.TypeOf((*MockInstrumentableCollector)(nil).CollectWithError), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectWithError", reflect.TypeOf((*MockInstrumentableCollector)(nil).CollectWithError), arg0, arg1)
 }
 
 // Describe mocks base method.